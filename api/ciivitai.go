@@ -12,25 +12,23 @@ import (
 	"civitai-model-downloader/util"
 )
 
-const (
-	baseURL        = "https://civitai.com"
-	requestTimeout = 30 * time.Second
-)
+// RequestTimeout bounds each API request; the api-timeout setting
+// overrides it.
+var RequestTimeout = 30 * time.Second
+
+// baseURL is a variable so tests can point the client at a local server.
+var baseURL = "https://civitai.com"
 
 func doGet(ctx context.Context, url string) ([]byte, error) {
-	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	ctx, cancel := context.WithTimeout(ctx, RequestTimeout)
 	defer cancel()
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
-	if util.AuthHeader != nil {
-		if v := util.AuthHeader["Authorization"]; v != "" && v != "Bearer " {
-			for k, v := range util.AuthHeader {
-				req.Header.Set(k, v)
-			}
-		}
+	for k, v := range util.AuthHeader() {
+		req.Header.Set(k, v)
 	}
 	req.Header.Set("User-Agent", "cvtcli/2.0")
 	req.Header.Set("Accept", "application/json")
@@ -44,7 +42,7 @@ func doGet(ctx context.Context, url string) ([]byte, error) {
 	if resp.StatusCode >= 400 {
 		limit := io.LimitReader(resp.Body, 512)
 		body, _ := io.ReadAll(limit)
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
+		return nil, &util.HTTPError{Code: resp.StatusCode, Body: string(body), RetryAfter: resp.Header.Get("Retry-After")}
 	}
 
 	data, err := io.ReadAll(resp.Body)
@@ -108,3 +106,44 @@ func GetModelByHash(ctx context.Context, hash string) (*dto.ModelVersionFull, er
 	}
 	return &model, nil
 }
+
+func GetImages(ctx context.Context, req *dto.ImageRequest) (*dto.ImagesResponse, error) {
+	u := baseURL + "/api/v1/images" + req.QueryString()
+	data, err := doGet(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	var resp dto.ImagesResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+	return &resp, nil
+}
+
+// GetMe returns the account the configured token belongs to. Civitai
+// answers 401 for a missing or invalid token.
+func GetMe(ctx context.Context) (*dto.MeResponse, error) {
+	data, err := doGet(ctx, baseURL+"/api/v1/me")
+	if err != nil {
+		return nil, err
+	}
+	var me dto.MeResponse
+	if err := json.Unmarshal(data, &me); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+	return &me, nil
+}
+
+// GetEnums returns the values Civitai accepts for model types, file
+// types and base models.
+func GetEnums(ctx context.Context) (*dto.EnumsResponse, error) {
+	data, err := doGet(ctx, baseURL+"/api/v1/enums")
+	if err != nil {
+		return nil, err
+	}
+	var resp dto.EnumsResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+	return &resp, nil
+}
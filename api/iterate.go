@@ -0,0 +1,183 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"net/http"
+	"strconv"
+	"time"
+
+	"civitai-model-downloader/dto"
+	"civitai-model-downloader/util"
+)
+
+const (
+	defaultPageInterval = 500 * time.Millisecond
+	maxThrottleRetries  = 5
+)
+
+// IterOption tunes how IterateModels and IterateImages walk a listing.
+type IterOption func(*iterConfig)
+
+type iterConfig struct {
+	maxItems int
+	interval time.Duration
+}
+
+// WithMaxItems stops the iteration after n items. n <= 0 means no cap.
+func WithMaxItems(n int) IterOption {
+	return func(c *iterConfig) { c.maxItems = n }
+}
+
+// WithPageInterval sets the minimum delay between two page requests.
+// A zero interval disables client-side rate limiting.
+func WithPageInterval(d time.Duration) IterOption {
+	return func(c *iterConfig) { c.interval = d }
+}
+
+// IterateModels walks every page of GET /api/v1/models for req and
+// yields the items one by one. Requests with a Page set are walked in
+// page mode, everything else follows Metadata.NextCursor. The caller's
+// req is never modified.
+//
+// Iteration stops at the first error, which is yielded with a zero
+// ModelItem. HTTP 429 responses are retried with backoff before
+// giving up.
+func IterateModels(ctx context.Context, req *dto.ModelRequest, opts ...IterOption) iter.Seq2[dto.ModelItem, error] {
+	page := dto.ModelRequest{}
+	if req != nil {
+		page = *req
+	}
+	return paginate(ctx, opts, &page.Page, &page.Cursor, func(ctx context.Context) ([]dto.ModelItem, *dto.Metadata, error) {
+		resp, err := GetModelInfo(ctx, &page)
+		if err != nil {
+			return nil, nil, err
+		}
+		return resp.Items, resp.Metadata, nil
+	})
+}
+
+// IterateImages walks GET /api/v1/images the same way IterateModels
+// walks the model listing.
+func IterateImages(ctx context.Context, req *dto.ImageRequest, opts ...IterOption) iter.Seq2[dto.ImageItem, error] {
+	page := dto.ImageRequest{}
+	if req != nil {
+		page = *req
+	}
+	return paginate(ctx, opts, &page.Page, &page.Cursor, func(ctx context.Context) ([]dto.ImageItem, *dto.Metadata, error) {
+		resp, err := GetImages(ctx, &page)
+		if err != nil {
+			return nil, nil, err
+		}
+		return resp.Items, resp.Metadata, nil
+	})
+}
+
+// paginate drives fetch until the listing is exhausted. fetch reads the
+// request that pagePtr and cursorPtr point into; paginate advances one
+// of them after every page, depending on whether the walk started in
+// page mode (page set) or cursor mode.
+func paginate[T any](ctx context.Context, opts []IterOption, pagePtr **int, cursorPtr **string,
+	fetch func(context.Context) ([]T, *dto.Metadata, error)) iter.Seq2[T, error] {
+	cfg := iterConfig{interval: defaultPageInterval}
+	for _, o := range opts {
+		o(&cfg)
+	}
+	pageMode := *pagePtr != nil
+	startPage, startCursor := *pagePtr, *cursorPtr
+
+	return func(yield func(T, error) bool) {
+		// Rewind so the sequence can be ranged over more than once.
+		*pagePtr, *cursorPtr = startPage, startCursor
+		var (
+			zero    T
+			yielded int
+			last    time.Time
+		)
+		for {
+			if err := waitInterval(ctx, last, cfg.interval); err != nil {
+				yield(zero, err)
+				return
+			}
+			last = time.Now()
+
+			items, md, err := fetchPage(ctx, fetch)
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+				yielded++
+				if cfg.maxItems > 0 && yielded >= cfg.maxItems {
+					return
+				}
+			}
+
+			if md == nil || len(items) == 0 {
+				return
+			}
+			if pageMode {
+				if md.NextPage == "" {
+					return
+				}
+				next := md.CurrentPage + 1
+				if md.CurrentPage == 0 {
+					next = **pagePtr + 1
+				}
+				*pagePtr = &next
+			} else {
+				if md.NextCursor == "" {
+					return
+				}
+				cursor := md.NextCursor
+				*cursorPtr = &cursor
+			}
+		}
+	}
+}
+
+// fetchPage runs fetch, retrying while the API throttles us.
+func fetchPage[T any](ctx context.Context, fetch func(context.Context) ([]T, *dto.Metadata, error)) ([]T, *dto.Metadata, error) {
+	backoff := 2 * time.Second
+	for attempt := 0; ; attempt++ {
+		items, md, err := fetch(ctx)
+		var httpErr *util.HTTPError
+		if err == nil || !errors.As(err, &httpErr) || httpErr.Code != http.StatusTooManyRequests || attempt >= maxThrottleRetries {
+			return items, md, err
+		}
+
+		wait := backoff
+		if s, convErr := strconv.Atoi(httpErr.RetryAfter); convErr == nil && s > 0 {
+			wait = time.Duration(s) * time.Second
+		}
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-time.After(wait):
+		}
+		backoff *= 2
+	}
+}
+
+func waitInterval(ctx context.Context, last time.Time, interval time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if last.IsZero() || interval <= 0 {
+		return nil
+	}
+	d := time.Until(last.Add(interval))
+	if d <= 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"civitai-model-downloader/dto"
+)
+
+func TestIterateModelsCursor(t *testing.T) {
+	pages := map[string]dto.ModelsResponse{
+		"":   {Items: []dto.ModelItem{{ID: 1}, {ID: 2}}, Metadata: &dto.Metadata{NextCursor: "c2"}},
+		"c2": {Items: []dto.ModelItem{{ID: 3}}, Metadata: &dto.Metadata{}},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(pages[r.URL.Query().Get("cursor")])
+	}))
+	defer srv.Close()
+	defer func(u string) { baseURL = u }(baseURL)
+	baseURL = srv.URL
+
+	var ids []int
+	for item, err := range IterateModels(context.Background(), nil, WithPageInterval(0)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, item.ID)
+	}
+	if len(ids) != 3 || ids[2] != 3 {
+		t.Fatalf("unexpected ids %v", ids)
+	}
+
+	ids = ids[:0]
+	for item, err := range IterateModels(context.Background(), nil, WithPageInterval(0), WithMaxItems(2)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, item.ID)
+	}
+	if len(ids) != 2 {
+		t.Fatalf("max items not honored: %v", ids)
+	}
+}
+
+func TestIterateModelsCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request sent after cancellation")
+	}))
+	defer srv.Close()
+	defer func(u string) { baseURL = u }(baseURL)
+	baseURL = srv.URL
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var errs []error
+	for _, err := range IterateModels(ctx, &dto.ModelRequest{}) {
+		errs = append(errs, err)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], context.Canceled) {
+		t.Fatalf("got %v, want a single context.Canceled", errs)
+	}
+}
+
+func TestIterateImagesPages(t *testing.T) {
+	var queries []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.RawQuery)
+		switch r.URL.Query().Get("page") {
+		case "1":
+			json.NewEncoder(w).Encode(dto.ImagesResponse{
+				Items:    []dto.ImageItem{{ID: 1}, {ID: 2}},
+				Metadata: &dto.Metadata{CurrentPage: 1, NextPage: "next"},
+			})
+		case "2":
+			json.NewEncoder(w).Encode(dto.ImagesResponse{
+				Items:    []dto.ImageItem{{ID: 3}},
+				Metadata: &dto.Metadata{CurrentPage: 2},
+			})
+		default:
+			t.Errorf("unexpected query %q", r.URL.RawQuery)
+		}
+	}))
+	defer srv.Close()
+	defer func(u string) { baseURL = u }(baseURL)
+	baseURL = srv.URL
+
+	page, version, nsfw := 1, 7, "Soft"
+	req := &dto.ImageRequest{Page: &page, ModelVersionID: &version, NSFW: &nsfw}
+	var ids []int
+	for img, err := range IterateImages(context.Background(), req, WithPageInterval(0)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, img.ID)
+	}
+	if len(ids) != 3 || ids[2] != 3 {
+		t.Fatalf("unexpected ids %v", ids)
+	}
+	if len(queries) != 2 {
+		t.Fatalf("expected 2 requests, got %v", queries)
+	}
+	for _, q := range queries {
+		if !strings.Contains(q, "modelVersionId=7") || !strings.Contains(q, "nsfw=Soft") {
+			t.Errorf("filters not kept across pages: %q", q)
+		}
+	}
+	if *req.Page != 1 {
+		t.Errorf("caller's request modified: page %d", *req.Page)
+	}
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"civitai-model-downloader/i18n"
+	"civitai-model-downloader/log"
+	"civitai-model-downloader/util"
+)
+
+// threadsAuto as --numThreads tunes the number of connections while
+// downloading.
+const threadsAuto = "auto"
+
+// maxAdaptiveThreads is the most connections adaptive mode opens for a
+// file.
+const maxAdaptiveThreads = 32
+
+// adaptiveChunkSize is the chunk size of adaptive downloads from hosts
+// without a remembered one. Connections are only given back between
+// chunks, so chunks must be small enough for a new limit to take effect
+// within seconds.
+const adaptiveChunkSize = 16 << 20
+
+// threadSetting parses --numThreads: a positive number, or auto.
+func threadSetting() (n int, auto bool, err error) {
+	if flagThreads == threadsAuto {
+		return maxAdaptiveThreads, true, nil
+	}
+	n, err = strconv.Atoi(flagThreads)
+	if err != nil || n <= 0 {
+		return 0, false, fmt.Errorf("invalid --numThreads %q (want a positive number or auto)", flagThreads)
+	}
+	return n, false, nil
+}
+
+// startTuning puts the download of url under adaptive concurrency. It
+// returns the chunk size to use, the remembered one for the host unless
+// chunkSize is set, and a function that ends tuning.
+func startTuning(url string, chunkSize int64) (int64, func()) {
+	tuner := util.NewTuner(url, maxAdaptiveThreads)
+	unwatch := tuner.Watch(url)
+	if chunkSize == 0 {
+		chunkSize = tuner.ChunkSize()
+	}
+	if chunkSize == 0 {
+		chunkSize = adaptiveChunkSize
+	}
+	return chunkSize, func() {
+		unwatch()
+		tuner.Close()
+		if threads, rate := tuner.Result(); rate > 0 {
+			log.Logger().Sugar().Debugf(i18n.T("adaptive concurrency settled on %d connections at %s/s"), threads, formatSize(int64(rate)))
+		}
+	}
+}
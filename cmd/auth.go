@@ -0,0 +1,307 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"civitai-model-downloader/api"
+	"civitai-model-downloader/dto"
+	"civitai-model-downloader/i18n"
+	"civitai-model-downloader/log"
+	"civitai-model-downloader/secret"
+	"civitai-model-downloader/util"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+var (
+	flagProfile    string
+	flagLoginToken string
+)
+
+// Token sources reported by resolveToken.
+const (
+	tokenFromEnv    = "environment"
+	tokenFromConfig = "config file (plaintext)"
+	tokenNone       = "none"
+)
+
+// activeProfile is --profile, CVTCLI_PROFILE or the config's "profile"
+// key, in that order.
+func activeProfile(vc *viper.Viper) string {
+	if flagProfile != "" {
+		return flagProfile
+	}
+	if p := vc.GetString("profile"); p != "" {
+		return p
+	}
+	return "default"
+}
+
+// applyProfile overlays the settings under profiles.<name> onto the
+// top-level ones. Environment variables and flags still win. With
+// strict set, naming a profile the config doesn't define is an error.
+func applyProfile(vc *viper.Viper, strict bool) error {
+	name := activeProfile(vc)
+	sub := vc.GetStringMap("profiles." + name)
+	if len(sub) == 0 {
+		if strict && flagProfile != "" && name != "default" {
+			return fmt.Errorf("profile %q is not defined in %s", name, vc.ConfigFileUsed())
+		}
+		return nil
+	}
+	return vc.MergeConfigMap(sub)
+}
+
+// openSecretStore returns the keyring, or the encrypted token file in
+// the config dir when no keyring is running.
+func openSecretStore() secret.Store {
+	s := secret.Open(filepath.Join(util.ConfigDir(), "tokens.enc"))
+	if fs, ok := s.(*secret.FileStore); ok {
+		fs.Passphrase = promptPassphrase
+	}
+	return s
+}
+
+func promptPassphrase() (string, error) {
+	if p := os.Getenv(secret.PassphraseEnv); p != "" {
+		return p, nil
+	}
+	if !isInteractive() {
+		return "", secret.ErrNoPassphrase
+	}
+	return readSecret("Passphrase for the token file: ")
+}
+
+// readSecret reads a line from the terminal without echoing it.
+func readSecret(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	b, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// resolvedToken remembers the result of resolveToken for the rest of
+// the run.
+var resolvedToken struct {
+	done          bool
+	token, source string
+}
+
+// resolveToken finds the API token for the active profile: the
+// environment first, then an api-key in config.yaml, then the secret
+// store. The store is only opened when neither has a token, and the
+// lookup runs once per process.
+func resolveToken(vc *viper.Viper) (token, source string) {
+	if !resolvedToken.done {
+		resolvedToken.token, resolvedToken.source = lookupToken(vc)
+		resolvedToken.done = true
+	}
+	return resolvedToken.token, resolvedToken.source
+}
+
+// rememberToken makes resolveToken return token, e.g. once the
+// first-run prompt has stored it.
+func rememberToken(token, source string) {
+	resolvedToken.token, resolvedToken.source, resolvedToken.done = token, source, true
+}
+
+func lookupToken(vc *viper.Viper) (token, source string) {
+	for _, env := range []string{"CVTCLI_API_KEY", "CIVITAI_TOKEN"} {
+		if t := os.Getenv(env); t != "" {
+			return t, tokenFromEnv
+		}
+	}
+	// applyProfile leaves the top-level api-key in place, so another
+	// profile would inherit the default account's token from it.
+	name := activeProfile(vc)
+	key := "api-key"
+	if name != "default" {
+		key = "profiles." + name + ".api-key"
+	}
+	if t := vc.GetString(key); t != "" {
+		log.Logger().Sugar().Warnf(i18n.T("api-key is stored in plaintext in %s; run 'cvtcli auth login' to move it to the keyring"), vc.ConfigFileUsed())
+		return t, tokenFromConfig
+	}
+	store := openSecretStore()
+	t, err := store.Get(name)
+	switch {
+	case err == nil:
+		return t, store.Name()
+	case !errors.Is(err, secret.ErrNotFound):
+		log.Logger().Sugar().Warnf(i18n.T("cannot read token from %s: %v"), store.Name(), err)
+	}
+	return "", tokenNone
+}
+
+// loadConfigFile reads the config file into vc when it exists and
+// applies the active profile, which may not exist yet. Commands that
+// must work on a fresh machine (config, auth) use it instead of the
+// root hook.
+func loadConfigFile(vc *viper.Viper) error {
+	path := configFilePath()
+	if util.FileExists(path) {
+		vc.SetConfigFile(path)
+		if err := vc.ReadInConfig(); err != nil {
+			return err
+		}
+	}
+	return applyProfile(vc, false)
+}
+
+var authCommand = &cobra.Command{
+	Use:   "auth",
+	Short: "Manage the Civitai API token of a profile",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := loadConfigOnly(cmd); err != nil {
+			return err
+		}
+		// login and status check the token against the API, which must
+		// go through the configured proxy, TLS settings and timeouts.
+		if err := configureNetwork(appConfig); err != nil {
+			return err
+		}
+		if err := configureTimeouts(appConfig); err != nil {
+			return err
+		}
+		return configureTransport(appConfig, downloadConcurrency())
+	},
+}
+
+var authLoginCommand = &cobra.Command{
+	Use:   "login",
+	Short: "Check a token against Civitai and store it in the keyring",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		token := flagLoginToken
+		var err error
+		switch {
+		case token != "":
+		case term.IsTerminal(int(os.Stdin.Fd())):
+			token, err = readSecret("Civitai API token (https://civitai.com/user/account): ")
+		default:
+			token, err = bufio.NewReader(os.Stdin).ReadString('\n')
+			if errors.Is(err, io.EOF) {
+				err = nil
+			}
+			token = strings.TrimSpace(token)
+		}
+		if err != nil {
+			return err
+		}
+		if token == "" {
+			return errors.New("no token given")
+		}
+
+		me, err := checkToken(token)
+		if err != nil {
+			return err
+		}
+		profile := activeProfile(appConfig)
+		store := openSecretStore()
+		if err := store.Set(profile, token); err != nil {
+			return fmt.Errorf("store token: %w", err)
+		}
+		if err := removePlaintextToken(profile); err != nil {
+			log.Logger().Sugar().Warnf(i18n.T("token stored, but the plaintext api-key could not be removed from the config: %v"), err)
+		}
+		fmt.Printf(i18n.T("logged in as %s (profile %s, stored in %s)\n"), me.Username, profile, store.Name())
+		return nil
+	},
+}
+
+var authLogoutCommand = &cobra.Command{
+	Use:   "logout",
+	Short: "Remove the stored token of the profile",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		profile := activeProfile(appConfig)
+		store := openSecretStore()
+		err := store.Delete(profile)
+		if errors.Is(err, secret.ErrNotFound) {
+			fmt.Printf(i18n.T("profile %s has no stored token\n"), profile)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf(i18n.T("removed the token of profile %s from %s\n"), profile, store.Name())
+		return nil
+	},
+}
+
+var authStatusCommand = &cobra.Command{
+	Use:   "status",
+	Short: "Show where the token comes from and whether Civitai accepts it",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		profile := activeProfile(appConfig)
+		token, source := resolveToken(appConfig)
+		fmt.Printf(i18n.T("profile: %s\ntoken:   %s\n"), profile, source)
+		if token == "" {
+			return &downloadError{Code: exitInvalidToken, Msg: i18n.T("not logged in; run 'cvtcli auth login'")}
+		}
+		me, err := checkToken(token)
+		if err != nil {
+			return err
+		}
+		fmt.Printf(i18n.T("account: %s (%s)\n"), me.Username, me.Tier)
+		return nil
+	},
+}
+
+// checkToken asks /api/v1/me who token belongs to.
+func checkToken(token string) (*dto.MeResponse, error) {
+	defer util.SetToken(util.Token())
+	util.SetToken(token)
+
+	// api.RequestTimeout bounds the request.
+	me, err := api.GetMe(context.Background())
+	var httpErr *util.HTTPError
+	if errors.As(err, &httpErr) && (httpErr.Code == 401 || httpErr.Code == 403) {
+		return nil, &downloadError{Code: exitInvalidToken, Msg: i18n.T("Civitai rejected the token"), Err: err}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return me, nil
+}
+
+// removePlaintextToken drops the api-key of profile from the config
+// file once the token lives in the secret store.
+func removePlaintextToken(profile string) error {
+	path := configFilePath()
+	if !util.FileExists(path) {
+		return nil
+	}
+	file := viper.New()
+	file.SetConfigFile(path)
+	if err := file.ReadInConfig(); err != nil {
+		return err
+	}
+	key := "api-key"
+	if profile != "default" || file.IsSet("profiles.default.api-key") {
+		key = "profiles." + profile + ".api-key"
+	}
+	if file.GetString(key) == "" {
+		return nil
+	}
+	file.Set(key, "")
+	return file.WriteConfigAs(path)
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&flagProfile, "profile", os.Getenv("CVTCLI_PROFILE"), "configuration profile to use")
+	rootCmd.RegisterFlagCompletionFunc("profile", completeProfiles)
+	authLoginCommand.Flags().StringVar(&flagLoginToken, "token", "", "token to store (default: prompt, or read from stdin)")
+	authCommand.AddCommand(authLoginCommand, authLogoutCommand, authStatusCommand)
+	rootCmd.AddCommand(authCommand)
+}
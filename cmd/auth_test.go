@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"civitai-model-downloader/secret"
+	"civitai-model-downloader/util"
+)
+
+func TestLookupTokenPerProfile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", "unix:path="+filepath.Join(dir, "no-bus"))
+	t.Setenv("CVTCLI_API_KEY", "")
+	t.Setenv("CIVITAI_TOKEN", "")
+	t.Setenv(secret.PassphraseEnv, "test")
+
+	path := filepath.Join(dir, "config.yaml")
+	cfg := "api-key: default-token\nprofiles:\n  work:\n    threads: 4\n"
+	if err := os.WriteFile(path, []byte(cfg), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := secret.NewFileStore(filepath.Join(util.ConfigDir(), "tokens.enc")).Set("work", "work-token"); err != nil {
+		t.Fatal(err)
+	}
+	oldPath, oldProfile := ConfigFilePath, flagProfile
+	defer func() { ConfigFilePath, flagProfile = oldPath, oldProfile }()
+	ConfigFilePath = path
+
+	for _, c := range []struct{ profile, token, source string }{
+		{"default", "default-token", tokenFromConfig},
+		{"work", "work-token", "encrypted file " + filepath.Join(util.ConfigDir(), "tokens.enc")},
+	} {
+		flagProfile = c.profile
+		vc := newConfig()
+		if err := loadConfigFile(vc); err != nil {
+			t.Fatal(err)
+		}
+		token, source := lookupToken(vc)
+		if token != c.token || source != c.source {
+			t.Errorf("profile %s: got %q from %s, want %q from %s", c.profile, token, source, c.token, c.source)
+		}
+	}
+}
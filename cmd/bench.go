@@ -0,0 +1,358 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"civitai-model-downloader/api"
+	"civitai-model-downloader/i18n"
+	"civitai-model-downloader/log"
+	"civitai-model-downloader/util"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagBenchUrl       string
+	flagBenchVersionId string
+	flagBenchLocal     bool
+	flagBenchLocalRate string
+	flagBenchBytes     string
+	flagBenchThreads   []string
+	flagBenchChunks    []string
+	flagBenchWrite     bool
+)
+
+// benchRetries is how often a failed range is tried, like the
+// downloader's MaxRetries.
+const benchRetries = 3
+
+// benchResult is one cell of the benchmark matrix: the first --bytes of
+// the target downloaded with one combination of threads and chunk size.
+type benchResult struct {
+	Threads int
+	// Chunk is the chunk size as given, so it can go into config as is.
+	Chunk     string
+	ChunkSize int64
+	// Rate is the throughput in bytes/s.
+	Rate float64
+	// TTFB is the median time to the response headers of a range.
+	TTFB    time.Duration
+	Retries int
+	Err     error
+}
+
+var benchCommand = &cobra.Command{
+	Use:   "bench",
+	Short: "Measure download throughput for combinations of threads and chunk sizes",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		total := parseChunkSize(flagBenchBytes)
+		if total <= 0 {
+			return fmt.Errorf("invalid --bytes %q", flagBenchBytes)
+		}
+		matrix, err := benchMatrix(flagBenchThreads, flagBenchChunks)
+		if err != nil {
+			return err
+		}
+		ctx, stop := jobContext()
+		defer stop()
+
+		var url string
+		switch {
+		case flagBenchLocal:
+			var rate int64
+			if flagBenchLocalRate != "" {
+				if rate = parseChunkSize(flagBenchLocalRate); rate <= 0 {
+					return fmt.Errorf("invalid --local-rate %q", flagBenchLocalRate)
+				}
+			}
+			var stopServer func()
+			url, stopServer, err = startBenchServer(total, rate)
+			if err != nil {
+				return err
+			}
+			defer stopServer()
+		case flagBenchUrl != "":
+			url = flagBenchUrl
+		case flagBenchVersionId != "":
+			versionID, err := resolveLibraryID(cmd, flagBenchVersionId, true)
+			if err != nil {
+				return fmt.Errorf("--modelVersionId: %w", err)
+			}
+			version, err := api.GetModelByVersionId(ctx, versionID)
+			if err != nil {
+				return diagnose(ctx, err, nil, "")
+			}
+			url = version.DownloadURL
+		default:
+			return fmt.Errorf("specify --url, --modelVersionId or --local")
+		}
+
+		if !flagBenchLocal {
+			res, err := resolveDownload(ctx, url)
+			if err != nil {
+				return diagnose(ctx, err, nil, url)
+			}
+			if res.Size > 0 && res.Size < total {
+				total = res.Size
+			}
+		}
+		log.Logger().Sugar().Infof(i18n.T("benchmarking %s: %d runs of %s each"), url, len(matrix), formatSize(total))
+
+		results := make([]benchResult, 0, len(matrix))
+		for _, r := range matrix {
+			r = runBench(ctx, url, total, r)
+			if ctx.Err() != nil {
+				return nil
+			}
+			if r.Err != nil {
+				log.Logger().Sugar().Warnf(i18n.T("%d threads, chunk %s: %v"), r.Threads, r.Chunk, r.Err)
+			}
+			results = append(results, r)
+		}
+		printBench(results)
+
+		best, ok := recommend(results)
+		if !ok {
+			return fmt.Errorf("no run completed")
+		}
+		fmt.Printf(i18n.T("recommended: threads = %d, chunk-size = %s")+"\n", best.Threads, best.Chunk)
+		if !flagBenchWrite {
+			return nil
+		}
+		if err := writeConfigValues(map[string]any{"threads": best.Threads, "chunk-size": best.Chunk}); err != nil {
+			return err
+		}
+		log.Logger().Sugar().Infof(i18n.T("saved to %s"), configFilePath())
+		return nil
+	},
+}
+
+// benchConcurrency is the thread count of the largest run. The root
+// hook keeps a connection for each of its workers, as a download with
+// that many threads would; invalid counts are reported by the command.
+func benchConcurrency() int {
+	n := 0
+	for _, t := range flagBenchThreads {
+		if v, err := strconv.Atoi(t); err == nil {
+			n = max(n, v)
+		}
+	}
+	if n <= 0 {
+		return downloadConcurrency()
+	}
+	return n
+}
+
+// benchMatrix combines every thread count with every chunk size.
+func benchMatrix(threads, chunks []string) ([]benchResult, error) {
+	var matrix []benchResult
+	for _, t := range threads {
+		n, err := strconv.Atoi(t)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid thread count %q", t)
+		}
+		for _, c := range chunks {
+			size := parseChunkSize(c)
+			if size <= 0 {
+				return nil, fmt.Errorf("invalid chunk size %q", c)
+			}
+			matrix = append(matrix, benchResult{Threads: n, Chunk: c, ChunkSize: size})
+		}
+	}
+	return matrix, nil
+}
+
+// runBench fetches [0, total) of url in ranges of r.ChunkSize with
+// r.Threads workers, the way the downloader does, and fills in the
+// measurements.
+func runBench(ctx context.Context, url string, total int64, r benchResult) benchResult {
+	var (
+		next    atomic.Int64
+		retries atomic.Int64
+		mu      sync.Mutex
+		ttfbs   []time.Duration
+		errOnce sync.Once
+	)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	fail := func(err error) {
+		errOnce.Do(func() { r.Err = err })
+		cancel()
+	}
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for range r.Threads {
+		wg.Go(func() {
+			for {
+				from := next.Add(1) - 1
+				from *= r.ChunkSize
+				if from >= total || ctx.Err() != nil {
+					return
+				}
+				to := min(from+r.ChunkSize, total) - 1
+				var err error
+				for try := range benchRetries {
+					var ttfb time.Duration
+					if ttfb, err = fetchRange(ctx, url, from, to); err == nil {
+						mu.Lock()
+						ttfbs = append(ttfbs, ttfb)
+						mu.Unlock()
+						break
+					}
+					if ctx.Err() != nil {
+						return
+					}
+					if try < benchRetries-1 {
+						retries.Add(1)
+					}
+				}
+				if err != nil {
+					fail(err)
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+	if r.Err == nil {
+		r.Rate = float64(total) / time.Since(start).Seconds()
+	}
+	r.Retries = int(retries.Load())
+	if len(ttfbs) > 0 {
+		slices.Sort(ttfbs)
+		r.TTFB = ttfbs[len(ttfbs)/2]
+	}
+	return r
+}
+
+// fetchRange reads bytes from-to of url into the void and reports the
+// time to the response headers. It bypasses --limit-rate and the rate
+// schedule, which would cap every run at the same speed.
+func fetchRange(ctx context.Context, url string, from, to int64) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return 0, err
+	}
+	for k, v := range util.AuthHeader() {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", from, to))
+	start := time.Now()
+	resp, err := util.GetHttpClient().GetUnlimitedClient().Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	ttfb := time.Since(start)
+	if resp.StatusCode != http.StatusPartialContent {
+		if resp.StatusCode == http.StatusOK {
+			return 0, errors.New("the server ignores range requests")
+		}
+		return 0, &util.HTTPError{Code: resp.StatusCode}
+	}
+	n, err := io.Copy(io.Discard, resp.Body)
+	if err == nil && n != to-from+1 {
+		err = fmt.Errorf("short range: %d of %d bytes", n, to-from+1)
+	}
+	return ttfb, err
+}
+
+// recommend picks the setting to use: among the runs within 5% of the
+// fastest, the one with the fewest threads, then the fewest retries.
+func recommend(results []benchResult) (benchResult, bool) {
+	var fastest float64
+	for _, r := range results {
+		if r.Err == nil {
+			fastest = max(fastest, r.Rate)
+		}
+	}
+	if fastest == 0 {
+		return benchResult{}, false
+	}
+	var best benchResult
+	found := false
+	for _, r := range results {
+		if r.Err != nil || r.Rate < fastest*0.95 {
+			continue
+		}
+		if !found || r.Threads < best.Threads || r.Threads == best.Threads && r.Retries < best.Retries {
+			best, found = r, true
+		}
+	}
+	return best, found
+}
+
+func printBench(results []benchResult) {
+	fmt.Printf("%7s  %-6s  %14s  %9s  %7s\n", "threads", "chunk", "throughput", "ttfb", "retries")
+	for _, r := range results {
+		rate := "failed"
+		if r.Err == nil {
+			rate = formatSize(int64(r.Rate)) + "/s"
+		}
+		fmt.Printf("%7d  %-6s  %14s  %9s  %7d\n", r.Threads, r.Chunk, rate, r.TTFB.Round(time.Millisecond), r.Retries)
+	}
+}
+
+// startBenchServer serves size bytes of zeros on a local port, with
+// ranges, each connection capped at rate bytes/s (0 is unlimited).
+func startBenchServer(size, rate int64) (string, func(), error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", nil, err
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rate > 0 {
+			w = &pacedWriter{ResponseWriter: w, rate: rate, start: time.Now()}
+		}
+		http.ServeContent(w, r, "bench.bin", time.Time{}, io.NewSectionReader(zeros{}, 0, size))
+	})}
+	go srv.Serve(ln)
+	return "http://" + ln.Addr().String() + "/bench.bin", func() { srv.Close() }, nil
+}
+
+type zeros struct{}
+
+func (zeros) ReadAt(p []byte, off int64) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// pacedWriter holds a response to rate bytes/s.
+type pacedWriter struct {
+	http.ResponseWriter
+	rate    int64
+	start   time.Time
+	written int64
+}
+
+func (w *pacedWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	w.written += int64(n)
+	due := w.start.Add(time.Duration(float64(w.written) / float64(w.rate) * float64(time.Second)))
+	time.Sleep(time.Until(due))
+	return n, err
+}
+
+func init() {
+	benchCommand.Flags().StringVarP(&flagBenchUrl, "url", "u", "", "URL to benchmark")
+	benchCommand.Flags().StringVarP(&flagBenchVersionId, "modelVersionId", "v", "", "benchmark the primary file of this model version")
+	benchCommand.Flags().BoolVar(&flagBenchLocal, "local", false, "benchmark against a local test server")
+	benchCommand.Flags().StringVar(&flagBenchLocalRate, "local-rate", "", "per-connection speed of the local server, e.g. 20M; empty is unlimited")
+	benchCommand.Flags().StringVarP(&flagBenchBytes, "bytes", "n", "128M", "bytes to download per run")
+	benchCommand.Flags().StringSliceVar(&flagBenchThreads, "threads", []string{"2", "4", "8", "16"}, "thread counts to try")
+	benchCommand.Flags().StringSliceVar(&flagBenchChunks, "chunks", []string{"8M", "32M"}, "chunk sizes to try")
+	benchCommand.Flags().BoolVar(&flagBenchWrite, "write-config", false, "store the recommended threads and chunk-size in the config file")
+	benchCommand.RegisterFlagCompletionFunc("modelVersionId", completeVersionIDs)
+	rootCmd.AddCommand(benchCommand)
+}
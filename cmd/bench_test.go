@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"civitai-model-downloader/util"
+)
+
+func TestBenchLocal(t *testing.T) {
+	const total = 3<<20 + 17
+	url, stop, err := startBenchServer(total, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	matrix, err := benchMatrix([]string{"1", "3"}, []string{"1M"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range matrix {
+		r = runBench(context.Background(), url, total, r)
+		if r.Err != nil || r.Rate <= 0 || r.Retries != 0 {
+			t.Errorf("%d threads: %+v", r.Threads, r)
+		}
+	}
+
+	if _, err := benchMatrix([]string{"0"}, []string{"1M"}); err == nil {
+		t.Error("accepted 0 threads")
+	}
+	if _, err := benchMatrix([]string{"2"}, []string{"lots"}); err == nil {
+		t.Error("accepted chunk size \"lots\"")
+	}
+}
+
+func TestBenchIgnoresRateLimit(t *testing.T) {
+	util.SetRateLimit(func(time.Time) int64 { return 1 << 20 })
+	defer util.SetRateLimit(nil)
+	const total = 4 << 20
+	url, stop, err := startBenchServer(total, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stop()
+	r := runBench(context.Background(), url, total, benchResult{Threads: 2, Chunk: "1M", ChunkSize: 1 << 20})
+	if r.Err != nil {
+		t.Fatal(r.Err)
+	}
+	if r.Rate < 2<<20 {
+		t.Errorf("throughput %.0f B/s: capped by the rate limit", r.Rate)
+	}
+}
+
+func TestRecommend(t *testing.T) {
+	results := []benchResult{
+		{Threads: 2, Chunk: "8M", Rate: 40},
+		{Threads: 4, Chunk: "8M", Rate: 97},
+		{Threads: 8, Chunk: "8M", Rate: 100},
+		{Threads: 16, Chunk: "8M", Err: errors.New("HTTP 429")},
+	}
+	best, ok := recommend(results)
+	if !ok || best.Threads != 4 {
+		t.Errorf("recommended %+v, want 4 threads", best)
+	}
+	if _, ok := recommend(results[3:]); ok {
+		t.Error("recommended a failed run")
+	}
+}
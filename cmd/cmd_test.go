@@ -1,7 +1,12 @@
 package cmd
 
 import (
+	"strings"
 	"testing"
+
+	"civitai-model-downloader/i18n"
+
+	"github.com/spf13/cobra"
 )
 
 func TestDownloadCommandArgs(t *testing.T) {
@@ -10,3 +15,24 @@ func TestDownloadCommandArgs(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestLocalizeCommand(t *testing.T) {
+	defer i18n.SetLang(i18n.Lang())
+	i18n.SetLang(i18n.Chinese)
+	c := &cobra.Command{Use: "x"}
+	localizeCommand(c)
+	tmpl := c.UsageTemplate()
+	for _, h := range usageHeadings {
+		if strings.Contains(tmpl, h) {
+			t.Errorf("%q left untranslated", h)
+		}
+	}
+	if !strings.Contains(tmpl, "\n"+i18n.T("Global Flags:")+"\n") {
+		t.Errorf("Global Flags: heading mangled:\n%s", tmpl)
+	}
+	for _, k := range configSchema {
+		if i18n.T(k.Desc) == k.Desc {
+			t.Errorf("description of config key %s left untranslated", k.Key)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"civitai-model-downloader/api"
+	"civitai-model-downloader/dto"
+	"civitai-model-downloader/i18n"
+	"civitai-model-downloader/log"
+	"civitai-model-downloader/util"
+
+	"github.com/spf13/cobra"
+)
+
+// completionTimeout bounds the API lookups of a completion request; a
+// shell waiting on TAB should never hang on a slow network.
+const completionTimeout = 5 * time.Second
+
+// enumsCacheTTL is how long the /api/v1/enums answer is reused. The
+// lists change only when Civitai adds a base model.
+const enumsCacheTTL = 24 * time.Hour
+
+// isCompletionRequest reports whether cmd is cobra's hidden command
+// answering a shell completion request, or one printing a completion
+// script; both write to stdout for the shell to read.
+func isCompletionRequest(cmd *cobra.Command) bool {
+	switch cmd.Name() {
+	case cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+		return true
+	}
+	return cmd.HasParent() && cmd.Parent().Name() == "completion"
+}
+
+// prepareCompletion replaces the root hook for completion requests.
+// Anything printed to stdout would end up as a candidate, and the shell
+// owns the terminal, so logging is silenced and neither the first-run
+// setup nor the token store (which may prompt) is touched. Lookups are
+// anonymous unless a token is set in the environment.
+func prepareCompletion() error {
+	log.Configure(log.Options{Level: "fatal"})
+	if err := loadConfigFile(appConfig); err != nil {
+		return nil
+	}
+	configureNetwork(appConfig)
+	configureTimeouts(appConfig)
+	configureTransport(appConfig, downloadConcurrency())
+	for _, env := range []string{"CVTCLI_API_KEY", "CIVITAI_TOKEN"} {
+		if t := os.Getenv(env); t != "" {
+			util.SetToken(t)
+			break
+		}
+	}
+	return nil
+}
+
+// fixedCompletion completes a flag from a fixed list of values.
+func fixedCompletion(values ...string) cobra.CompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+		return values, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// libraryCompletion is the candidate for a library entry with the given
+// ID and name, if it matches toComplete. Digits complete the ID. Other
+// input completes the entry's libraryKey, matched by prefix and
+// extended from exactly what was typed: shells drop every candidate
+// that doesn't start with the current word.
+func libraryCompletion(id int, name, toComplete string) (cobra.Completion, bool) {
+	ids := strconv.Itoa(id)
+	if isDigits(toComplete) {
+		return cobra.CompletionWithDesc(ids, name), strings.HasPrefix(ids, toComplete)
+	}
+	key, typed := libraryKey(name), strings.ToLower(toComplete)
+	if key == "" || !strings.HasPrefix(key, typed) || len(typed) != len(toComplete) {
+		return "", false
+	}
+	return cobra.CompletionWithDesc(toComplete+key[len(typed):], name+" ("+ids+")"), true
+}
+
+// isDigits reports whether s is empty or all ASCII digits.
+func isDigits(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' }) < 0
+}
+
+// completeModelIDs offers the models in the local library, by ID or by
+// name.
+func completeModelIDs(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	entries, _ := loadLibrary(libraryDir(cmd))
+	var out []cobra.Completion
+	seen := map[int]bool{}
+	for _, e := range entries {
+		if e.ModelID == 0 || seen[e.ModelID] {
+			continue
+		}
+		if c, ok := libraryCompletion(e.ModelID, e.Model, toComplete); ok {
+			seen[e.ModelID] = true
+			out = append(out, c)
+		}
+	}
+	return out, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeVersionIDs offers the versions of the --modelId already on
+// the command line, looked up on Civitai; those complete by ID only.
+// Without one it falls back to the versions in the local library,
+// which also complete by name.
+func completeVersionIDs(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	var out []cobra.Completion
+	if f := cmd.Flag("modelId"); f != nil && f.Value.String() != "" {
+		modelID, err := resolveLibraryID(cmd, f.Value.String(), false)
+		if err != nil || !isDigits(toComplete) {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+		defer cancel()
+		model, err := api.GetModelById(ctx, modelID)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		for _, v := range model.ModelVersions {
+			if strings.HasPrefix(strconv.Itoa(v.ID), toComplete) {
+				out = append(out, cobra.CompletionWithDesc(strconv.Itoa(v.ID), v.Name+" ("+v.BaseModel+")"))
+			}
+		}
+		return out, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+	}
+
+	entries, _ := loadLibrary(libraryDir(cmd))
+	seen := map[int]bool{}
+	for _, e := range entries {
+		if e.VersionID == 0 || seen[e.VersionID] {
+			continue
+		}
+		if c, ok := libraryCompletion(e.VersionID, versionName(e), toComplete); ok {
+			seen[e.VersionID] = true
+			out = append(out, c)
+		}
+	}
+	return out, cobra.ShellCompDirectiveNoFileComp
+}
+
+// enumCompletion completes a comma-separated list flag from one of the
+// /api/v1/enums lists.
+func enumCompletion(pick func(*dto.EnumsResponse) []string) cobra.CompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+		enums, err := cachedEnums()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		// Complete the last element of "LORA,Check".
+		prefix := ""
+		if i := strings.LastIndex(toComplete, ","); i >= 0 {
+			prefix = toComplete[:i+1]
+		}
+		var out []cobra.Completion
+		for _, v := range pick(enums) {
+			out = append(out, prefix+v)
+		}
+		return out, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// cachedEnums returns /api/v1/enums, cached in util.CacheDir for
+// enumsCacheTTL.
+func cachedEnums() (*dto.EnumsResponse, error) {
+	path := filepath.Join(util.CacheDir(), "enums.json")
+	if fi, err := os.Stat(path); err == nil && time.Since(fi.ModTime()) < enumsCacheTTL {
+		var enums dto.EnumsResponse
+		if data, err := os.ReadFile(path); err == nil && json.Unmarshal(data, &enums) == nil {
+			return &enums, nil
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+	enums, err := api.GetEnums(ctx)
+	if err != nil {
+		return nil, err
+	}
+	saveJSON(path, enums)
+	return enums, nil
+}
+
+func modelTypes(e *dto.EnumsResponse) []string { return e.ModelType }
+
+func baseModels(e *dto.EnumsResponse) []string {
+	if len(e.ActiveBaseModel) > 0 {
+		return e.ActiveBaseModel
+	}
+	return e.BaseModel
+}
+
+// completeProfiles offers the profiles defined in the config file.
+func completeProfiles(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	var out []cobra.Completion
+	for name := range appConfig.GetStringMap("profiles") {
+		out = append(out, name)
+	}
+	slices.Sort(out)
+	return out, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeConfigKeys offers the keys of configSchema as the first
+// argument.
+func completeConfigKeys(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var out []cobra.Completion
+	for _, k := range configSchema {
+		out = append(out, cobra.CompletionWithDesc(k.Key, i18n.T(k.Desc)))
+	}
+	return out, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeDirs restricts completion to directories.
+func completeDirs(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	return nil, cobra.ShellCompDirectiveFilterDirs
+}
+
+// completeSpecFiles restricts completion to YAML files.
+func completeSpecFiles(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	return []cobra.Completion{"yaml", "yml"}, cobra.ShellCompDirectiveFilterFileExt
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestCompleteModelIDsFromLibrary(t *testing.T) {
+	dir := t.TempDir()
+	rv := nameInfo{Model: "Realistic Vision", Version: "V6.0", ModelID: 4201, VersionID: 130072}
+	dream := nameInfo{Model: "DreamShaper", Version: "8", ModelID: 4384, VersionID: 128713}
+	for _, d := range []struct {
+		info nameInfo
+		path string
+	}{
+		{rv, dir + "/rv.safetensors"},
+		{dream, dir + "/ds.safetensors"},
+		{rv, dir + "/rv.safetensors"}, // re-download replaces the entry
+	} {
+		if err := recordDownload(dir, d.info, d.path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := recordDownload(dir, nameInfo{Filename: "bare.bin"}, dir+"/bare.bin"); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := loadLibrary(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Path != "rv.safetensors" {
+		t.Fatalf("library = %+v", entries)
+	}
+
+	flagOutputDir = dir
+	t.Cleanup(func() { flagOutputDir = "" })
+	for _, tc := range []struct {
+		toComplete string
+		want       []string
+	}{
+		{"", []string{"4201\tRealistic Vision", "4384\tDreamShaper"}},
+		{"438", []string{"4384\tDreamShaper"}},
+		{"real", []string{"realistic-vision\tRealistic Vision (4201)"}},
+		{"Dream", []string{"Dreamshaper\tDreamShaper (4384)"}},
+		{"vision", nil},
+	} {
+		got, _ := completeModelIDs(downloadCommand, nil, tc.toComplete)
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("complete %q = %q, want %q", tc.toComplete, got, tc.want)
+		}
+	}
+}
+
+// TestCompleteThroughShell asks for completions the way the shell
+// scripts do, through cobra's __complete command, and checks every
+// candidate extends the typed word; shells drop any that don't.
+func TestCompleteThroughShell(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	info := nameInfo{Model: "Realistic Vision", Version: "V6.0", ModelID: 4201, VersionID: 130072}
+	if err := recordDownload(dir, info, dir+"/rv.safetensors"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { flagOutputDir = "" })
+	// Flag state outlives Execute; a --help left by another test would
+	// suppress completion.
+	if f := downloadCommand.Flags().Lookup("help"); f != nil {
+		f.Value.Set("false")
+		f.Changed = false
+	}
+
+	for _, tc := range []struct {
+		flag, typed, want string
+	}{
+		{"--modelId", "42", "4201\tRealistic Vision"},
+		{"--modelId", "real", "realistic-vision\tRealistic Vision (4201)"},
+		{"--modelVersionId", "Realistic-vision-v", "Realistic-vision-v6-0\tRealistic Vision / V6.0 (130072)"},
+	} {
+		var out bytes.Buffer
+		rootCmd.SetOut(&out)
+		rootCmd.SetArgs([]string{cobra.ShellCompRequestCmd, "download", "--downloadDir", dir, tc.flag, tc.typed})
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		got := lines[:len(lines)-1] // the last line is the directive
+		if !slices.Equal(got, []string{tc.want}) {
+			t.Errorf("%s %s<TAB> = %q, want %q", tc.flag, tc.typed, got, tc.want)
+		}
+		for _, c := range got {
+			if !strings.HasPrefix(c, tc.typed) {
+				t.Errorf("%s %s<TAB>: %q does not extend the typed word", tc.flag, tc.typed, c)
+			}
+		}
+
+		value, _, _ := strings.Cut(tc.want, "\t")
+		id, err := resolveLibraryID(downloadCommand, value, tc.flag == "--modelVersionId")
+		if err != nil || !isDigits(id) {
+			t.Errorf("resolve %q = %q, %v", value, id, err)
+		}
+	}
+	rootCmd.SetOut(nil)
+}
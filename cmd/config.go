@@ -0,0 +1,309 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"civitai-model-downloader/i18n"
+	"civitai-model-downloader/util"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// configKey documents one setting of config.yaml. Default also fixes
+// the type `config set` converts values to.
+type configKey struct {
+	Key     string
+	Default any
+	Desc    string
+}
+
+// configSchema lists every setting cvtcli reads from config.yaml. Each
+// key can also be set through the environment as CVTCLI_<KEY>, with
+// dashes turned into underscores (e.g. CVTCLI_DOWNLOAD_DIR). Any key
+// may be repeated under profiles.<name> to override it for a profile:
+//
+//	threads: 8
+//	profiles:
+//	  work:
+//	    download-dir: /srv/models
+//	    proxy: http://gateway:3128
+var configSchema = []configKey{
+	{"profile", "default", "profile used when --profile is not given"},
+	{"lang", "", "language of messages and help: en or zh; empty follows LANG"},
+	{"api-key", "", "Civitai API token in plaintext; prefer 'cvtcli auth login' (also CVTCLI_API_KEY or CIVITAI_TOKEN)"},
+	{"download-dir", ".", "directory downloads are written to"},
+	{"threads", 8, "concurrent download threads per file, or auto to tune them to the connection"},
+	{"chunk-size", "", "chunk size, e.g. 16M or 1G; empty picks one automatically"},
+	{"layout", "flat", "directory layout under download-dir: flat, or by-type for <type>/<base model>/"},
+	{"naming", "{filename}", "file name template; placeholders: {filename} {name} {ext} {model} {version} {modelId} {versionId} {type} {baseModel}"},
+	{"if-exists", "skip", "when the target file exists: skip, overwrite, rename (add a number), or verify (download again on sha256 mismatch)"},
+	{"verify", "off", "when a download's SHA256 differs from the API's (it never gets its final name): off or warn keep the .part file, strict deletes it"},
+	{"proxy", "", "proxy URL for all requests: http, https, socks5 or socks5h, optionally user:pass@; empty uses HTTP_PROXY / HTTPS_PROXY, none disables"},
+	{"ca-bundle", "", "PEM file of extra CA certificates to trust, e.g. a corporate gateway's"},
+	{"client-cert", "", "PEM client certificate for gateways that require mutual TLS"},
+	{"client-key", "", "PEM private key of client-cert"},
+	{"insecure", false, "skip TLS certificate verification (unsafe; last resort)"},
+	{"dial-timeout", "30s", "time limit for opening a connection"},
+	{"tls-timeout", "10s", "time limit for the TLS handshake of a connection"},
+	{"response-header-timeout", "", "time limit for a server to start answering; empty follows stall-timeout"},
+	{"keep-alive", "30s", "interval of TCP keep-alive probes on open connections; 0 disables them"},
+	{"http2", false, "use HTTP/2 with servers that offer it; chunk requests then share one connection, so more threads add little"},
+	{"tcp-buffer", "", "socket send and receive buffer size, e.g. 4M; empty lets the OS tune them"},
+	{"api-timeout", "30s", "time limit for each API request"},
+	{"stall-timeout", "60s", "abort and retry a transfer that receives no data for this long; 0 disables"},
+	{"rate-limit", "", "bandwidth cap for downloads, e.g. 20M; empty is unlimited"},
+	{"rate-schedule", "", "time windows with their own cap, e.g. \"22:00-07:00=0, 12:00-13:00=50M\"; rate-limit applies outside them"},
+	{"log-level", "info", "log level: debug, info, warn or error"},
+	{"log-format", "console", "log format: console, json or logfmt"},
+	{"log-file", "", "also write logs to this file, rotated at 50 MB"},
+}
+
+// appConfig is the configuration loaded for the running command.
+var appConfig = newConfig()
+
+// newConfig returns a viper instance with schema defaults and
+// environment overrides, but no file loaded yet.
+func newConfig() *viper.Viper {
+	vc := viper.New()
+	for _, k := range configSchema {
+		vc.SetDefault(k.Key, k.Default)
+	}
+	vc.SetEnvPrefix("CVTCLI")
+	vc.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	vc.AutomaticEnv()
+	vc.BindEnv("api-key", "CVTCLI_API_KEY", "CIVITAI_TOKEN")
+	return vc
+}
+
+// settingSource names where the value of key came from, for error
+// messages: its environment variable, or the key in the config file.
+func settingSource(vc *viper.Viper, key string) string {
+	env := "CVTCLI_" + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+	if _, ok := os.LookupEnv(env); ok {
+		return env
+	}
+	if f := vc.ConfigFileUsed(); f != "" {
+		return key + " in " + f
+	}
+	return key
+}
+
+func lookupConfigKey(key string) (configKey, bool) {
+	i := slices.IndexFunc(configSchema, func(k configKey) bool { return k.Key == key })
+	if i < 0 {
+		return configKey{}, false
+	}
+	return configSchema[i], true
+}
+
+// configAnnotation marks a flag whose default comes from a config key.
+const configAnnotation = "cvtcli_config_key"
+
+// bindFlagToConfig makes flag fall back to the config key when it isn't
+// given on the command line.
+func bindFlagToConfig(flags *pflag.FlagSet, flag, key string) {
+	flags.SetAnnotation(flag, configAnnotation, []string{key})
+}
+
+// applyConfigToFlags copies config values into every bound flag the user
+// didn't set, so commands keep reading plain flag variables.
+func applyConfigToFlags(cmd *cobra.Command, vc *viper.Viper) error {
+	var firstErr error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		keys := f.Annotations[configAnnotation]
+		if len(keys) == 0 || f.Changed || !vc.IsSet(keys[0]) {
+			return
+		}
+		if err := f.Value.Set(vc.GetString(keys[0])); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("config %s: %w", keys[0], err)
+		}
+	})
+	return firstErr
+}
+
+var configCommand = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect and edit the cvtcli configuration",
+	// The config commands must work before a valid config exists, so
+	// they skip the root hook and only load what is there.
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return loadConfigOnly(cmd)
+	},
+}
+
+var configInitCommand = &cobra.Command{
+	Use:   "init",
+	Short: "Write a config file listing every setting at its default, commented out",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := configFilePath()
+		force, _ := cmd.Flags().GetBool("force")
+		if util.FileExists(path) && !force {
+			return fmt.Errorf("%s already exists (use --force to overwrite)", path)
+		}
+		if err := writeDefaultConfig(path); err != nil {
+			return err
+		}
+		fmt.Println(path)
+		return nil
+	},
+}
+
+var configGetCommand = &cobra.Command{
+	Use:               "get <key>",
+	Short:             "Print the effective value of a setting",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeConfigKeys,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, ok := lookupConfigKey(args[0]); !ok {
+			return fmt.Errorf("unknown config key %q", args[0])
+		}
+		v := appConfig.GetString(args[0])
+		if reveal, _ := cmd.Flags().GetBool("reveal"); args[0] == "api-key" && v != "" && !reveal {
+			v = maskToken(v)
+		}
+		fmt.Println(v)
+		return nil
+	},
+}
+
+var configSetCommand = &cobra.Command{
+	Use:               "set <key> <value>",
+	Short:             "Change a setting in the config file (of a profile with --profile)",
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeConfigKeys,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, ok := lookupConfigKey(args[0])
+		if !ok {
+			return fmt.Errorf("unknown config key %q", args[0])
+		}
+		value, err := convertConfigValue(key, args[1])
+		if err != nil {
+			return err
+		}
+		return writeConfigValues(map[string]any{key.Key: value})
+	},
+}
+
+// writeConfigValues stores settings in the config file, under the
+// profile given with --profile if any.
+func writeConfigValues(values map[string]any) error {
+	path := configFilePath()
+	// Write only what the file holds, not env overrides or defaults.
+	file := viper.New()
+	file.SetConfigFile(path)
+	if util.FileExists(path) {
+		if err := file.ReadInConfig(); err != nil {
+			return err
+		}
+	} else if err := createConfigFile(path); err != nil {
+		return err
+	}
+	for key, value := range values {
+		target := key
+		if flagProfile != "" && key != "profile" {
+			target = "profiles." + flagProfile + "." + key
+		}
+		file.Set(target, value)
+	}
+	return file.WriteConfigAs(path)
+}
+
+var configListCommand = &cobra.Command{
+	Use:   "list",
+	Short: "List every setting with its effective value",
+	Run: func(cmd *cobra.Command, args []string) {
+		width := 0
+		for _, k := range configSchema {
+			width = max(width, len(k.Key))
+		}
+		for _, k := range configSchema {
+			v := appConfig.GetString(k.Key)
+			if k.Key == "profile" {
+				v = activeProfile(appConfig)
+			}
+			if k.Key == "api-key" && v != "" {
+				v = maskToken(v)
+			}
+			fmt.Printf("%-*s = %-20s # %s\n", width, k.Key, v, i18n.T(k.Desc))
+		}
+	},
+}
+
+var configPathCommand = &cobra.Command{
+	Use:   "path",
+	Short: "Print the path of the config file",
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Println(configFilePath())
+	},
+}
+
+func convertConfigValue(key configKey, raw string) (any, error) {
+	switch key.Default.(type) {
+	case int:
+		n, err := strconv.Atoi(raw)
+		if err != nil && key.Key == "threads" && raw == threadsAuto {
+			return raw, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s expects a number", key.Key)
+		}
+		return n, nil
+	case bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%s expects true or false", key.Key)
+		}
+		return b, nil
+	}
+	return raw, nil
+}
+
+// writeDefaultConfig writes a config file that lists every schema key
+// with its description and default, all commented out. Nothing is set,
+// so a later change of a default still reaches the user.
+func writeDefaultConfig(path string) error {
+	var b strings.Builder
+	b.WriteString("# cvtcli configuration. Every setting is shown commented out at its\n")
+	b.WriteString("# default; uncomment a line to change it. 'cvtcli config list' shows\n")
+	b.WriteString("# the effective values.\n")
+	for _, k := range configSchema {
+		value := fmt.Sprint(k.Default)
+		if _, ok := k.Default.(string); ok {
+			value = strconv.Quote(value)
+		}
+		fmt.Fprintf(&b, "\n# %s\n# %s: %s\n", k.Desc, k.Key, value)
+	}
+	if err := createConfigFile(path); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(b.String()), 0600)
+}
+
+func createConfigFile(path string) error {
+	f, err := util.CreateFile(path)
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Chmod(path, 0600)
+}
+
+func maskToken(t string) string {
+	if len(t) <= 8 {
+		return "********"
+	}
+	return t[:4] + "…" + t[len(t)-4:]
+}
+
+func init() {
+	configInitCommand.Flags().Bool("force", false, "overwrite an existing config file")
+	configGetCommand.Flags().Bool("reveal", false, "print api-key in full instead of masked")
+	configCommand.AddCommand(configInitCommand, configGetCommand, configSetCommand, configListCommand, configPathCommand)
+	rootCmd.AddCommand(configCommand)
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func TestApplyConfigToFlags(t *testing.T) {
+	c := &cobra.Command{Use: "x"}
+	var threads int
+	var dir string
+	c.Flags().IntVar(&threads, "numThreads", 8, "")
+	c.Flags().StringVar(&dir, "downloadDir", "", "")
+	bindFlagToConfig(c.Flags(), "numThreads", "threads")
+	bindFlagToConfig(c.Flags(), "downloadDir", "download-dir")
+	if err := c.Flags().Parse([]string{"--downloadDir", "/from/flag"}); err != nil {
+		t.Fatal(err)
+	}
+
+	vc := newConfig()
+	vc.Set("threads", 16)
+	vc.Set("download-dir", "/from/config")
+	if err := applyConfigToFlags(c, vc); err != nil {
+		t.Fatal(err)
+	}
+	if threads != 16 {
+		t.Errorf("threads = %d, want value from config", threads)
+	}
+	if dir != "/from/flag" {
+		t.Errorf("downloadDir = %q, explicit flag must win", dir)
+	}
+}
+
+func TestNameTemplate(t *testing.T) {
+	n := nameInfo{Filename: "lora.safetensors", Model: "Cute", Version: "v2", VersionID: 42}
+	if got := n.expand("{model}-{version}-{versionId}.{ext}"); got != "Cute-v2-42.safetensors" {
+		t.Errorf("got %q", got)
+	}
+	if got := n.expand("{type}"); got != "lora.safetensors" {
+		t.Errorf("empty expansion should fall back to the file name, got %q", got)
+	}
+}
+
+func TestOutputPathContainment(t *testing.T) {
+	dir := t.TempDir()
+	vc := newConfig()
+	vc.Set("layout", "by-type")
+	old := appConfig
+	appConfig = vc
+	t.Cleanup(func() { appConfig = old })
+
+	n := nameInfo{Filename: "../../.bashrc", Type: "../..", BaseModel: "/etc"}
+	got, err := n.outputPath(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "_", "_etc", "_.._.bashrc"); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	vc.Set("naming", "../{filename}")
+	if _, err := (nameInfo{Filename: "x.bin"}).outputPath(dir); err == nil {
+		t.Error("a template leaving the download dir should be rejected")
+	}
+}
+
+func TestWriteDefaultConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := writeDefaultConfig(path); err != nil {
+		t.Fatal(err)
+	}
+	file := viper.New()
+	file.SetConfigFile(path)
+	if err := file.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if keys := file.AllKeys(); len(keys) != 0 {
+		t.Fatalf("template sets %v; defaults must stay built in", keys)
+	}
+
+	// Uncommenting the template must yield exactly the defaults.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var uncommented []string
+	for _, line := range strings.Split(string(data), "\n") {
+		for _, k := range configSchema {
+			if strings.HasPrefix(line, "# "+k.Key+": ") {
+				uncommented = append(uncommented, strings.TrimPrefix(line, "# "))
+			}
+		}
+	}
+	file = viper.New()
+	file.SetConfigType("yaml")
+	if err := file.ReadConfig(strings.NewReader(strings.Join(uncommented, "\n"))); err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range configSchema {
+		if got, want := file.GetString(k.Key), fmt.Sprint(k.Default); got != want {
+			t.Errorf("%s: template has %q, default is %q", k.Key, got, want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"civitai-model-downloader/dto"
+	"civitai-model-downloader/i18n"
+	"civitai-model-downloader/log"
+	"civitai-model-downloader/util"
+)
+
+// downloadCreator fetches the catalog of one creator into outputDir.
+// Versions already recorded in the creator's state file are skipped,
+// so reruns only pick up new models and versions.
+func downloadCreator(ctx context.Context, username, outputDir string) error {
+	state, err := loadSeenState(statePath(outputDir, "creator-"+util.SanitizeFilename(username)))
+	if err != nil {
+		return fmt.Errorf("load state: %w", err)
+	}
+
+	req := &dto.ModelRequest{
+		Username:   &username,
+		Types:      flagTypes,
+		BaseModels: flagBaseModels,
+	}
+	versions, err := versionLimit()
+	if err != nil {
+		return err
+	}
+	files, err := parseFileFilter(flagFiles)
+	if err != nil {
+		return err
+	}
+	stats, err := syncModels(ctx, req, outputDir, state, syncOptions{
+		BaseModels: flagBaseModels,
+		Versions:   versions,
+		Files:      files,
+		Format:     flagFormat,
+	})
+	if err != nil {
+		return err
+	}
+	log.Logger().Sugar().Infof(i18n.T("creator %s: %d downloaded, %d already present, %d failed"), username, stats.Fetched, stats.Skipped, stats.Failed)
+	return stats.err()
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"civitai-model-downloader/api"
+	"civitai-model-downloader/dto"
+	"civitai-model-downloader/i18n"
+	"civitai-model-downloader/util"
+)
+
+// Exit codes reported by Execute for diagnosed download failures.
+const (
+	exitFailure       = 1
+	exitInvalidToken  = 3
+	exitLoginRequired = 4
+	exitEarlyAccess   = 5
+	exitUnavailable   = 6
+	exitRegionBlocked = 7
+	exitAccessDenied  = 8
+	exitTimeout       = 9
+)
+
+// downloadError is a failure that has been explained to the user. Code
+// becomes the process exit code.
+type downloadError struct {
+	Code int
+	Msg  string
+	Err  error
+}
+
+func (e *downloadError) Error() string { return e.Msg }
+
+func (e *downloadError) Unwrap() error { return e.Err }
+
+// diagnose turns an HTTP failure from the API or the download probe
+// into a downloadError explaining why the file can't be fetched.
+// version may be nil; it is then looked up from downloadUrl when that is
+// a Civitai /api/download/models/{id} link. Errors that aren't HTTP
+// errors are returned unchanged.
+func diagnose(ctx context.Context, err error, version *dto.ModelVersionFull, downloadUrl string) error {
+	var httpErr *util.HTTPError
+	if !errors.As(err, &httpErr) {
+		return err
+	}
+	if version == nil {
+		version = lookupVersion(ctx, downloadUrl)
+	}
+	fail := func(code int, format string, args ...any) error {
+		return &downloadError{Code: code, Msg: fmt.Sprintf(i18n.T(format), args...), Err: err}
+	}
+
+	switch {
+	case isRegionBlock(httpErr):
+		return fail(exitRegionBlocked, "this resource is not available in your region (HTTP %d)", httpErr.Code)
+	case version != nil && version.Status != "" && !strings.EqualFold(version.Status, "Published"):
+		return fail(exitUnavailable, "version %d is %s and can no longer be downloaded", version.ID, strings.ToLower(version.Status))
+	case version != nil && strings.EqualFold(version.Availability, "Private"):
+		return fail(exitUnavailable, "version %d is private", version.ID)
+	case httpErr.Code == http.StatusNotFound || httpErr.Code == http.StatusGone:
+		return fail(exitUnavailable, "not found: the model or version was deleted or never existed (HTTP %d)", httpErr.Code)
+	case httpErr.Code == http.StatusUnauthorized || httpErr.Code == http.StatusForbidden:
+		if version != nil {
+			if until, ok := version.EarlyAccessUntil(time.Now()); ok {
+				return fail(exitEarlyAccess, "version %d is in early access until %s; it can be downloaded by supporters now or by everyone after that date",
+					version.ID, until.Local().Format("2006-01-02 15:04 MST"))
+			}
+		}
+		if !hasToken() {
+			return fail(exitLoginRequired, "this model requires a signed-in account: run 'cvtcli auth login' or set CVTCLI_API_KEY")
+		}
+		me, meErr := api.GetMe(ctx)
+		var meHTTP *util.HTTPError
+		if errors.As(meErr, &meHTTP) && (meHTTP.Code == http.StatusUnauthorized || meHTTP.Code == http.StatusForbidden) {
+			return fail(exitInvalidToken, "the configured api-key was rejected by Civitai; create a new one at https://civitai.com/user/account")
+		}
+		if me != nil {
+			return fail(exitAccessDenied, "account %s is not allowed to download this file (HTTP %d)", me.Username, httpErr.Code)
+		}
+		return fail(exitAccessDenied, "access denied (HTTP %d)", httpErr.Code)
+	}
+	return fail(exitFailure, "HTTP %d: %s", httpErr.Code, strings.TrimSpace(httpErr.Body))
+}
+
+func hasToken() bool {
+	return util.Token() != ""
+}
+
+// regionBlockMessages are the phrases of Civitai's geo-block page. A
+// bare "region" is not enough: storage errors such as S3's
+// AuthorizationHeaderMalformed talk about the bucket region.
+var regionBlockMessages = []string{
+	"not available in your region",
+	"not available in your country",
+}
+
+func isRegionBlock(httpErr *util.HTTPError) bool {
+	if httpErr.Code == http.StatusUnavailableForLegalReasons {
+		return true
+	}
+	body := strings.ToLower(httpErr.Body)
+	for _, msg := range regionBlockMessages {
+		if strings.Contains(body, msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupVersion fetches the version behind a Civitai download link, or
+// returns nil for other URLs and on any error.
+func lookupVersion(ctx context.Context, downloadUrl string) *dto.ModelVersionFull {
+	u, err := url.Parse(downloadUrl)
+	if err != nil {
+		return nil
+	}
+	id, ok := strings.CutPrefix(u.Path, "/api/download/models/")
+	if !ok || id == "" || strings.Contains(id, "/") {
+		return nil
+	}
+	version, err := api.GetModelByVersionId(ctx, id)
+	if err != nil {
+		return nil
+	}
+	return version
+}
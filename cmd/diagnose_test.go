@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"civitai-model-downloader/dto"
+	"civitai-model-downloader/util"
+)
+
+func TestDiagnose(t *testing.T) {
+	defer util.SetToken(util.Token())
+	util.SetToken("")
+	ctx := context.Background()
+	ends := time.Now().Add(72 * time.Hour)
+
+	cases := []struct {
+		name    string
+		err     error
+		version *dto.ModelVersionFull
+		code    int
+	}{
+		{"early access", &util.HTTPError{Code: 401}, &dto.ModelVersionFull{ID: 1, Status: "Published", EarlyAccessEndsAt: &ends}, exitEarlyAccess},
+		{"login", &util.HTTPError{Code: 401}, &dto.ModelVersionFull{ID: 1, Status: "Published"}, exitLoginRequired},
+		{"deleted", &util.HTTPError{Code: 404}, &dto.ModelVersionFull{ID: 1, Status: "Deleted"}, exitUnavailable},
+		{"not found", &util.HTTPError{Code: 404}, &dto.ModelVersionFull{ID: 1}, exitUnavailable},
+		{"region", &util.HTTPError{Code: 451}, nil, exitRegionBlocked},
+		{"region message", &util.HTTPError{Code: 400, Body: "Civitai is not available in your region"}, nil, exitRegionBlocked},
+		{"region 403", &util.HTTPError{Code: 403, Body: "This model is not available in your region."}, &dto.ModelVersionFull{ID: 1, Status: "Published"}, exitRegionBlocked},
+		{"storage region", &util.HTTPError{Code: 400, Body: "<Code>AuthorizationHeaderMalformed</Code><Message>the region 'us-east-1' is wrong; expecting 'auto'</Message>"}, nil, exitFailure},
+	}
+	for _, c := range cases {
+		var dlErr *downloadError
+		if err := diagnose(ctx, c.err, c.version, ""); !errors.As(err, &dlErr) || dlErr.Code != c.code {
+			t.Errorf("%s: got %v, want code %d", c.name, err, c.code)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"civitai-model-downloader/util"
+)
+
+// diskSpaceMargin is kept free on top of the file itself, so a
+// download never fills a filesystem to the last byte.
+const diskSpaceMargin = 64 << 20
+
+// checkDiskSpace fails when the filesystem that will hold path has less
+// room than the size bytes still missing from it. partPath is an
+// earlier partial download whose bytes already count. Platforms
+// without a free-space query are not checked.
+func checkDiskSpace(path, partPath string, size int64) error {
+	if size <= 0 {
+		return nil
+	}
+	need := size
+	if fi, err := os.Stat(partPath); err == nil {
+		need -= fi.Size()
+	}
+	dir := existingDir(filepath.Dir(path))
+	free, err := util.FreeSpace(dir)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("check free space on %s: %w", dir, err)
+	}
+	if need+diskSpaceMargin > int64(free) {
+		return fmt.Errorf("not enough disk space for %s: need %s, %s free on %s",
+			filepath.Base(path), formatSize(need), formatSize(int64(free)), dir)
+	}
+	return nil
+}
+
+// existingDir returns dir or its nearest ancestor that exists; the
+// download creates the rest.
+func existingDir(dir string) string {
+	for {
+		if fi, err := os.Stat(dir); err == nil && fi.IsDir() {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+		dir = parent
+	}
+}
+
+// formatSize renders n bytes with a binary unit, e.g. "6.5 GiB".
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/ianaindex"
+)
+
+// parseContentDisposition returns the file name of a Content-Disposition
+// header (RFC 6266). filename* (RFC 8187) wins over filename when both
+// are present and it decodes; its charset may be anything x/text knows.
+// Plain filename values may be tokens or quoted strings with quoted
+// pairs; bytes that aren't UTF-8 are read as ISO-8859-1, as RFC 6266
+// prescribes, and RFC 2047 encoded words sent by some servers are
+// decoded too. The result is not sanitized.
+func parseContentDisposition(cd string) (string, bool) {
+	params := dispositionParams(cd)
+	if v, ok := params["filename*"]; ok {
+		if name, err := decodeExtValue(v); err == nil && name != "" {
+			return name, true
+		}
+	}
+	v, ok := params["filename"]
+	if !ok || v == "" {
+		return "", false
+	}
+	if strings.HasPrefix(v, "=?") {
+		dec := mime.WordDecoder{CharsetReader: charsetReader}
+		if name, err := dec.DecodeHeader(v); err == nil {
+			return name, true
+		}
+	}
+	if !utf8.ValidString(v) {
+		if name, err := charmap.ISO8859_1.NewDecoder().String(v); err == nil {
+			return name, true
+		}
+	}
+	return v, true
+}
+
+// dispositionParams splits the parameters of a Content-Disposition
+// header into lower-cased names and unquoted values. It is lenient the
+// way browsers are: a missing disposition type, stray semicolons and
+// unterminated quotes are tolerated, and the first occurrence of a
+// parameter wins.
+func dispositionParams(cd string) map[string]string {
+	params := map[string]string{}
+	s := cd
+	// Skip the disposition type, unless the header starts right away
+	// with a parameter.
+	if i := strings.IndexByte(s, ';'); i >= 0 && !strings.Contains(s[:i], "=") {
+		s = s[i+1:]
+	} else if i < 0 && !strings.Contains(s, "=") {
+		return params
+	}
+	for {
+		s = strings.TrimLeft(s, " \t;")
+		if s == "" {
+			return params
+		}
+		eq := strings.IndexAny(s, "=;")
+		if eq < 0 || s[eq] == ';' {
+			// Parameter without a value.
+			if eq < 0 {
+				return params
+			}
+			s = s[eq:]
+			continue
+		}
+		name := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " \t")
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			value, s = readQuoted(s[1:])
+		} else {
+			end := strings.IndexByte(s, ';')
+			if end < 0 {
+				end = len(s)
+			}
+			value, s = strings.TrimSpace(s[:end]), s[end:]
+		}
+		if _, seen := params[name]; !seen && name != "" {
+			params[name] = value
+		}
+	}
+}
+
+// readQuoted reads a quoted-string body up to the closing quote,
+// resolving quoted pairs, and returns it with the rest of s.
+func readQuoted(s string) (string, string) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		case '"':
+			return b.String(), s[i+1:]
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), ""
+}
+
+// decodeExtValue decodes an RFC 8187 ext-value: charset'language'value,
+// where value is percent-encoded. Unlike form encoding, "+" is a
+// literal plus.
+func decodeExtValue(v string) (string, error) {
+	charset, rest, ok := strings.Cut(v, "'")
+	if !ok {
+		return "", fmt.Errorf("ext-value %q: missing charset", v)
+	}
+	_, encoded, ok := strings.Cut(rest, "'")
+	if !ok {
+		return "", fmt.Errorf("ext-value %q: missing language", v)
+	}
+	raw, err := url.PathUnescape(encoded)
+	if err != nil {
+		return "", err
+	}
+	if strings.EqualFold(charset, "utf-8") {
+		if !utf8.ValidString(raw) {
+			return "", fmt.Errorf("ext-value %q: invalid UTF-8", v)
+		}
+		return raw, nil
+	}
+	enc, err := lookupCharset(charset)
+	if err != nil {
+		return "", err
+	}
+	return enc.NewDecoder().String(raw)
+}
+
+// lookupCharset finds an encoding by its IANA name or alias, falling
+// back to the WHATWG labels browsers accept.
+func lookupCharset(name string) (encoding.Encoding, error) {
+	if enc, err := ianaindex.IANA.Encoding(name); err == nil && enc != nil {
+		return enc, nil
+	}
+	if enc, err := htmlindex.Get(name); err == nil {
+		return enc, nil
+	}
+	return nil, fmt.Errorf("unsupported charset %q", name)
+}
+
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	enc, err := lookupCharset(charset)
+	if err != nil {
+		return nil, err
+	}
+	return enc.NewDecoder().Reader(input), nil
+}
+
+// filenameFromURL is the last path element of u when it looks like a
+// file name, i.e. has an extension. Storage redirects usually end in
+// the real name; API paths such as /api/download/models/123 don't.
+func filenameFromURL(u *url.URL) string {
+	name := path.Base(u.Path)
+	if name == "." || name == "/" || path.Ext(name) == "" {
+		return ""
+	}
+	return name
+}
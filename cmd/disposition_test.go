@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestParseContentDisposition(t *testing.T) {
+	for _, tc := range []struct {
+		cd, want string
+	}{
+		{`attachment; filename="model.safetensors"`, "model.safetensors"},
+		{`attachment; filename=model.safetensors`, "model.safetensors"},
+		{`attachment; filename="a;b \"c\".ckpt"; size=10`, `a;b "c".ckpt`},
+		{`attachment; filename="fallback.bin"; filename*=UTF-8''a%2Bb%20c.safetensors`, "a+b c.safetensors"},
+		{`attachment; filename*=UTF-8''%E3%83%A2%E3%83%87%E3%83%AB.safetensors`, "モデル.safetensors"},
+		{`attachment; filename*=iso-8859-1'en'%E9t%E9.pt`, "été.pt"},
+		{`attachment; filename*=Shift_JIS''%83%82%83f%83%8B.pt`, "モデル.pt"},
+		{`attachment; filename*=x-unknown''a.pt; filename="b.pt"`, "b.pt"},
+		{"attachment; filename=\"\xe9t\xe9.pt\"", "été.pt"},
+		{`attachment; filename="=?UTF-8?B?5qih5Z6LLnB0?="`, "模型.pt"},
+		{`filename=bare.pt`, "bare.pt"},
+	} {
+		got, ok := parseContentDisposition(tc.cd)
+		if !ok || got != tc.want {
+			t.Errorf("%s: got %q (%v), want %q", tc.cd, got, ok, tc.want)
+		}
+	}
+	for _, cd := range []string{"", "attachment", "inline; size=3"} {
+		if got, ok := parseContentDisposition(cd); ok {
+			t.Errorf("%q: got %q, want no name", cd, got)
+		}
+	}
+}
+
+func TestFilenameFromURL(t *testing.T) {
+	for raw, want := range map[string]string{
+		"https://cdn.example.com/files/Cute%2BLora%20v2.safetensors?token=x": "Cute+Lora v2.safetensors",
+		"https://civitai.com/api/download/models/128713":                     "",
+		"https://cdn.example.com/":                                           "",
+	} {
+		u, _ := url.Parse(raw)
+		if got := filenameFromURL(u); got != want {
+			t.Errorf("%s: got %q, want %q", raw, got, want)
+		}
+	}
+}
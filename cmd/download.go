@@ -2,18 +2,18 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io"
-	"mime"
+	"math"
 	"net/http"
+	neturl "net/url"
 	"os"
-	"os/signal"
-	"path/filepath"
 	"strconv"
 	"strings"
-	"syscall"
 
 	"civitai-model-downloader/api"
+	"civitai-model-downloader/dto"
+	"civitai-model-downloader/i18n"
 	"civitai-model-downloader/log"
 	"civitai-model-downloader/util"
 
@@ -27,239 +27,382 @@ var (
 	flagVersionId    string
 	flagHash         string
 	flagOutputDir    string
-	flagThreads      int
+	flagThreads      string
 	flagChunkSizeStr string
 	flagMaxChunkSize int64
+	flagCreator      string
+	flagAllVersions  bool
+	flagTypes        []string
+	flagBaseModels   []string
+	flagFormat       string
+	flagFiles        string
+	flagVersions     string
 )
 
 var downloadCommand = &cobra.Command{
-	Use: "download",
-	Run: func(cmd *cobra.Command, args []string) {
+	Use:   "download",
+	Short: "Download a model, a version, its files or a creator's catalog",
+	RunE: func(cmd *cobra.Command, args []string) error {
 		var (
 			downloadUrl string
 			modelName   string
+			size        int64
+			hashes      *dto.FileHashes
+			version     *dto.ModelVersionFull
+			// The --if-exists decision taken before probing, reused
+			// when the probed name leads to the same path.
+			checkedPath   string
+			checkedResult string
 		)
 		outputDir := flagOutputDir
 		if outputDir == "" {
 			outputDir = "."
 		}
 
-		ctx, cancel := context.WithCancel(context.Background())
-		defer cancel()
+		ctx, stop := jobContext()
+		defer stop()
+
+		if flagCreator != "" {
+			if err := downloadCreator(ctx, flagCreator, outputDir); err != nil {
+				return fmt.Errorf("creator: %w", err)
+			}
+			return nil
+		}
+
+		files, err := parseFileFilter(flagFiles)
+		if err != nil {
+			return err
+		}
+		modelID, err := resolveLibraryID(cmd, flagModelId, false)
+		if err != nil {
+			return fmt.Errorf("--modelId: %w", err)
+		}
+		versionID, err := resolveLibraryID(cmd, flagVersionId, true)
+		if err != nil {
+			return fmt.Errorf("--modelVersionId: %w", err)
+		}
+		if _, err := ifExistsPolicy(); err != nil {
+			return err
+		}
+		if _, _, err := threadSetting(); err != nil {
+			return err
+		}
 
 		switch {
 		case flagUrl != "":
-			downloadUrl = flagUrl
-			var err error
-			modelName, err = resolveFilename(downloadUrl)
+			res, err := resolveDownload(ctx, flagUrl)
 			if err != nil {
-				log.Logger().Sugar().Errorf("resolve filename: %v", err)
-				return
+				return diagnose(ctx, err, nil, flagUrl)
+			}
+			if res.Name == "" {
+				return fmt.Errorf("%s: the server sent no file name", flagUrl)
+			}
+			downloadUrl, modelName, size = flagUrl, res.Name, res.Size
+		case flagHash != "" || versionID != "":
+			if flagHash != "" {
+				version, err = api.GetModelByHash(ctx, flagHash)
+			} else {
+				version, err = api.GetModelByVersionId(ctx, versionID)
 			}
-		case flagHash != "":
-			model, err := api.GetModelByHash(ctx, flagHash)
 			if err != nil {
-				log.Logger().Sugar().Errorf("api: %v", err)
-				return
+				return diagnose(ctx, err, nil, "")
 			}
-			downloadUrl = model.DownloadURL
-			modelName, err = resolveFilename(downloadUrl)
-			if err != nil && len(model.Files) > 0 && model.Files[0].Name != "" {
-				modelName = model.Files[0].Name
-			} else if err != nil {
-				log.Logger().Sugar().Errorf("resolve filename: %v", err)
-				return
+			if !files.isPrimary() || flagFormat != "" {
+				selected := selectFiles(version.Files, files, flagFormat)
+				failed, err := downloadFiles(ctx, selected, version.DownloadURL, fullNameInfo(version), outputDir)
+				if err != nil {
+					return err
+				}
+				return filesFailed(failed, len(selected))
 			}
-		case flagVersionId != "":
-			model, err := api.GetModelByVersionId(ctx, flagVersionId)
+			// A file kept by --if-exists needs no probe at all.
+			primary, hasPrimary := pickFile(version.Files, "")
+			if hasPrimary && primary.Name != "" {
+				info := fullNameInfo(version)
+				info.Filename = primary.Name
+				if checkedPath, err = info.outputPath(outputDir); err != nil {
+					return err
+				}
+				if checkedResult, err = checkExisting(checkedPath, primary.Hashes); err != nil {
+					return err
+				} else if checkedResult == "" {
+					return nil
+				}
+			}
+			res, err := resolveDownload(ctx, version.DownloadURL)
 			if err != nil {
-				log.Logger().Sugar().Errorf("api: %v", err)
-				return
+				return diagnose(ctx, err, version, version.DownloadURL)
+			}
+			downloadUrl, modelName, size = version.DownloadURL, res.Name, res.Size
+			if modelName == "" && hasPrimary {
+				modelName = primary.Name
 			}
-			downloadUrl = model.DownloadURL
-			modelName = model.Name
-		case flagModelId != "":
-			log.Logger().Error("model-id download not yet implemented, use --hash or --modelVersionId")
-			return
+			if modelName == "" {
+				return fmt.Errorf("%s: the server sent no file name", version.DownloadURL)
+			}
+			if hasPrimary {
+				hashes = primary.Hashes
+				if size == 0 {
+					size = fileSize(primary)
+				}
+			}
+		case modelID != "":
+			return downloadModel(ctx, modelID, files, outputDir)
 		default:
-			log.Logger().Error("specify --url, --hash, --modelVersionId, or --modelId")
-			return
+			return fmt.Errorf("specify --url, --hash, --modelVersionId, --modelId, or --creator")
 		}
 
-		outPath := filepath.Join(outputDir, modelName)
-		log.Logger().Sugar().Infof("downloading %s -> %s", downloadUrl, outPath)
-
-		cfg := &downloader.Config{
-			Concurrency: flagThreads,
-			ChunkSize:   parseChunkSize(flagChunkSizeStr),
-			MaxRetries:  3,
-			HTTPTimeout: 0,
-			Headers:     util.AuthHeader,
-			Resume:      true,
-			Logger:      log.Logger(),
+		info := nameInfo{}
+		if version != nil {
+			info = fullNameInfo(version)
 		}
-
-		sigCh := make(chan os.Signal, 1)
-		signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
-		go func() {
-			<-sigCh
-			log.Logger().Sugar().Info("interrupted, saving state...")
-			cancel()
-		}()
-
-		dl := downloader.New(downloadUrl, outPath, cfg)
-		err := dl.Download(ctx)
-		signal.Stop(sigCh)
-		close(sigCh)
-
+		info.Filename = modelName
+		outPath, err := info.outputPath(outputDir)
 		if err != nil {
-			log.Logger().Sugar().Errorf("download: %v", err)
-			return
+			return err
+		}
+		if outPath != checkedPath {
+			if outPath, err = checkExisting(outPath, hashes); err != nil {
+				return err
+			} else if outPath == "" {
+				return nil
+			}
+		} else {
+			outPath = checkedResult
+		}
+		if err := fetchFile(ctx, downloadUrl, outPath, size, hashes); err != nil {
+			return fmt.Errorf("download: %w", err)
 		}
-		log.Logger().Sugar().Infof("download complete: %s", outPath)
+		if err := recordDownload(outputDir, info, outPath); err != nil {
+			log.Logger().Sugar().Debugf("library: %v", err)
+		}
+		log.Logger().Sugar().Infof(i18n.T("download complete: %s"), outPath)
+		return nil
 	},
 }
 
-func resolveFilename(url string) (string, error) {
-	req, err := http.NewRequest("GET", url, nil)
+// downloadModel fetches the versions of a model selected by --versions
+// and --base-model, and the files of each selected by --files.
+func downloadModel(ctx context.Context, modelId string, files fileFilter, outputDir string) error {
+	model, err := api.GetModelById(ctx, modelId)
 	if err != nil {
-		return "", err
-	}
-	req.Header.Set("Range", "bytes=0-1")
-	if util.AuthHeader != nil {
-		for k, v := range util.AuthHeader {
-			req.Header.Set(k, v)
-		}
+		return diagnose(ctx, err, nil, "")
 	}
-	resp, err := util.GetHttpClient().GetRawClient().Do(req)
+	limit, err := versionLimit()
 	if err != nil {
-		return "", err
+		return err
 	}
-	// Drain the body so the TCP connection returns to the idle pool
-	// for reuse by the downloader's probe/chunk requests.
-	defer func() {
-		io.Copy(io.Discard, resp.Body)
-		resp.Body.Close()
-	}()
-
-	// Surface auth/permission/not-found errors with a clear message
-	// instead of the misleading "no Content-Disposition header".
-	if resp.StatusCode >= 400 {
-		return "", fmt.Errorf("HTTP %d when resolving filename (check api-key)", resp.StatusCode)
+	versions := selectVersions(model.ModelVersions, flagBaseModels, limit)
+	if len(versions) == 0 {
+		return fmt.Errorf("model %s has no matching versions", modelId)
 	}
+	var failed, total int
+	for _, v := range versions {
+		selected := selectFiles(v.Files, files, flagFormat)
+		if len(selected) == 0 {
+			log.Logger().Sugar().Warnf(i18n.T("%s / %s: no matching files"), model.Name, v.Name)
+			continue
+		}
+		n, err := downloadFiles(ctx, selected, v.DownloadURL, compactNameInfo(*model, v), outputDir)
+		if err != nil {
+			return err
+		}
+		failed += n
+		total += len(selected)
+	}
+	return filesFailed(failed, total)
+}
 
-	cd := resp.Header.Get("Content-Disposition")
-	if cd == "" {
-		return "", fmt.Errorf("no Content-Disposition header")
+// versionLimit resolves --versions, with --all-versions as a shorthand
+// for "all".
+func versionLimit() (int, error) {
+	if flagAllVersions {
+		return 0, nil
 	}
+	return parseVersionSpec(flagVersions)
+}
 
-	if name := parseRFC5987(cd); name != "" {
-		return name, nil
+// partSuffix marks a download in progress. Only complete, verified
+// files ever appear under their final name, so tools watching the
+// download dir (ComfyUI and the like) never pick up half a model.
+const partSuffix = ".part"
+
+// fetchFile runs the chunked downloader for a single URL into
+// outPath.part, verifies it against hashes and renames it to outPath.
+// size is the expected length in bytes, or 0 when unknown; it is used
+// to check free space up front and to reserve it for the file. Cancelling
+// ctx (e.g. on SIGINT) leaves the partial file and resume state on
+// disk so the next run continues where this one stopped.
+func fetchFile(ctx context.Context, downloadUrl, outPath string, size int64, hashes *dto.FileHashes) error {
+	partPath := outPath + partSuffix
+	if err := checkDiskSpace(outPath, partPath, size); err != nil {
+		return err
+	}
+	var stream *util.StreamHash
+	if verifying(hashes) {
+		stream = util.NewStreamHash(partPath)
+		defer stream.Close()
+		defer stream.Watch(downloadUrl)()
 	}
+	if err := reservePart(partPath, size); err != nil {
+		return err
+	}
+	log.Logger().Sugar().Infof(i18n.T("downloading %s -> %s"), downloadUrl, outPath)
 
-	if name := parseSimpleFilename(cd); name != "" {
-		return name, nil
+	threads, auto, err := threadSetting()
+	if err != nil {
+		return err
+	}
+	chunkSize := parseChunkSize(flagChunkSizeStr)
+	if auto {
+		var stopTuning func()
+		chunkSize, stopTuning = startTuning(downloadUrl, chunkSize)
+		defer stopTuning()
 	}
 
-	return "", fmt.Errorf("cannot parse filename from Content-Disposition: %s", cd)
-}
+	cfg := &downloader.Config{
+		Concurrency: threads,
+		ChunkSize:   chunkSize,
+		MaxRetries:  3,
+		// A chunk may legitimately take long; the client aborts the
+		// ones that stall instead.
+		HTTPTimeout: 0,
+		Headers:     util.AuthHeader(),
+		Resume:      true,
+		Logger:      log.Logger(),
+		HTTPClient:  util.GetHttpClient().GetRawClient(),
+	}
 
-func parseSimpleFilename(cd string) string {
-	_, params, err := mime.ParseMediaType(cd)
-	if err != nil {
-		idx := strings.Index(cd, "filename=")
-		if idx < 0 {
-			return ""
+	if err := downloader.New(downloadUrl, partPath, cfg).Download(ctx); err != nil {
+		if ctx.Err() != nil {
+			log.Logger().Sugar().Info(i18n.T("interrupted, download state saved"))
 		}
-		f := cd[idx+9:]
-		f = strings.Trim(f, `"`)
-		if i := strings.IndexByte(f, ';'); i > 0 {
-			f = f[:i]
-		}
-		return f
+		return err
+	}
+	if err := verifyDownload(partPath, size, hashes, stream); err != nil {
+		return err
 	}
-	return params["filename"]
+	return os.Rename(partPath, outPath)
 }
 
-func parseRFC5987(cd string) string {
-	idx := strings.Index(cd, "filename*=")
-	if idx < 0 {
-		return ""
+// reservePart creates a fresh partial file and reserves its final size
+// on disk. The file keeps length 0, which is what the downloader would
+// create itself: a full-length file could pass for a finished one when
+// it decides what to resume. An existing partial file is a download to
+// resume and is left alone.
+func reservePart(partPath string, size int64) error {
+	if size <= 0 || util.FileExists(partPath) {
+		return nil
 	}
-	rest := cd[idx+10:]
-	end := strings.IndexByte(rest, ';')
-	if end > 0 {
-		rest = rest[:end]
+	f, err := util.CreateFile(partPath)
+	if err != nil {
+		return err
 	}
-	rest = strings.Trim(rest, `"`)
-
-	encEnd := strings.IndexByte(rest, '\'')
-	if encEnd < 0 {
-		return ""
+	err = util.ReserveFile(f, size)
+	if cerr := f.Close(); err == nil {
+		err = cerr
 	}
-	langEnd := strings.IndexByte(rest[encEnd+1:], '\'')
-	if langEnd < 0 {
-		return ""
+	if errors.Is(err, errors.ErrUnsupported) {
+		// Off Linux, or on a filesystem without fallocate, nothing is
+		// reserved: the free-space check is all there is, and a
+		// concurrent writer can still fill the disk mid-download.
+		log.Logger().Sugar().Debugf(i18n.T("cannot reserve disk space for %s: %v"), partPath, err)
+		return nil
 	}
-	encoded := rest[encEnd+langEnd+2:]
-	encoding := strings.ToLower(rest[:encEnd])
-
-	var decoded string
-	switch encoding {
-	case "utf-8":
-		var err error
-		decoded, err = urlDecode(encoded)
-		if err != nil {
-			return ""
-		}
-	default:
-		return ""
+	if err != nil {
+		os.Remove(partPath)
+		return fmt.Errorf("reserve space for %s: %w", partPath, err)
 	}
-	return decoded
+	return nil
 }
 
-func urlDecode(s string) (string, error) {
-	var buf strings.Builder
-	for i := 0; i < len(s); i++ {
-		switch {
-		case s[i] == '%' && i+2 < len(s):
-			hi, lo := unhex(s[i+1]), unhex(s[i+2])
-			if hi < 0 || lo < 0 {
-				buf.WriteByte(s[i])
-			} else {
-				buf.WriteByte(byte(hi<<4) | byte(lo))
-				i += 2
-			}
-		case s[i] == '+':
-			buf.WriteByte(' ')
-		default:
-			buf.WriteByte(s[i])
-		}
+// fileSize is the length of f in bytes. Civitai publishes sizeKB as
+// bytes/1024, so the product is exact; anything else is treated as
+// unknown rather than reserving a wrong length.
+func fileSize(f dto.File) int64 {
+	b := f.SizeKB * 1024
+	if b <= 0 || b != math.Trunc(b) {
+		return 0
 	}
-	return buf.String(), nil
+	return int64(b)
+}
+
+// resolved is the outcome of the single probe made before a download.
+type resolved struct {
+	// URL is the signed storage URL the download URL redirected to.
+	// The downloader is still given the stable download URL, which is
+	// what its resume state records; the shared client maps it to the
+	// current signature and renews that when it expires.
+	URL  string
+	Name string
+	// Size is 0 when the server didn't tell.
+	Size int64
 }
 
-func unhex(c byte) int {
-	switch {
-	case '0' <= c && c <= '9':
-		return int(c - '0')
-	case 'a' <= c && c <= 'f':
-		return int(c - 'a' + 10)
-	case 'A' <= c && c <= 'F':
-		return int(c - 'A' + 10)
+// resolveDownload probes url once, following the redirect chain, and
+// captures the final URL, size and file name. The name comes from
+// Content-Disposition, or else the final URL's path; it is empty when
+// neither has one. The downloader's own probe of the final URL is then
+// answered from the probe, and its chunk requests go straight to the
+// final URL, so a download costs one round-trip to the redirect
+// service instead of one per request.
+func resolveDownload(ctx context.Context, url string) (*resolved, error) {
+	p, err := util.ProbeURL(ctx, url, util.AuthHeader())
+	if err != nil {
+		return nil, err
+	}
+	final, err := neturl.Parse(p.URL)
+	if err != nil {
+		return nil, err
+	}
+	// Civitai answers anonymous requests for login-only files with a
+	// redirect to its sign-in page; surface that as the 401 it is so
+	// diagnose can explain it.
+	if strings.HasPrefix(final.Path, "/login") {
+		return nil, &util.HTTPError{Code: http.StatusUnauthorized, Body: "redirected to login"}
+	}
+	r := &resolved{URL: p.URL, Size: p.Size}
+	if name, ok := parseContentDisposition(p.Header.Get("Content-Disposition")); ok {
+		r.Name = name
+	} else {
+		r.Name = filenameFromURL(final)
 	}
-	return -1
+	log.Logger().Sugar().Debugf("resolved %s -> %s (%d bytes)", url, p.URL, p.Size)
+	return r, nil
 }
 
 func init() {
 	downloadCommand.PersistentFlags().StringVarP(&flagUrl, "url", "u", "", "direct download URL")
-	downloadCommand.PersistentFlags().StringVarP(&flagModelId, "modelId", "m", "", "model ID")
+	downloadCommand.PersistentFlags().StringVarP(&flagModelId, "modelId", "m", "", "model ID, or a model name from the local library")
 	downloadCommand.PersistentFlags().StringVar(&flagHash, "hash", "", "model hash")
 	downloadCommand.PersistentFlags().StringVarP(&flagOutputDir, "downloadDir", "o", "", "output directory")
-	downloadCommand.PersistentFlags().StringVarP(&flagVersionId, "modelVersionId", "v", "", "model version ID")
-	downloadCommand.PersistentFlags().IntVarP(&flagThreads, "numThreads", "t", 8, "number of concurrent download threads")
+	downloadCommand.PersistentFlags().StringVarP(&flagVersionId, "modelVersionId", "v", "", "model version ID, or a version name from the local library")
+	downloadCommand.PersistentFlags().StringVarP(&flagThreads, "numThreads", "t", "8", "number of concurrent download threads, or auto to tune them to the connection")
 	downloadCommand.PersistentFlags().StringVarP(&flagChunkSizeStr, "chunkSize", "c", "", "chunk size for dynamic worker pool (e.g. 16M, 1G, 16777216; empty=auto)")
+	downloadCommand.PersistentFlags().StringVar(&flagCreator, "creator", "", "download every model published by this username")
+	downloadCommand.PersistentFlags().BoolVar(&flagAllVersions, "all-versions", false, "download every version instead of only the latest")
+	downloadCommand.PersistentFlags().StringSliceVar(&flagTypes, "type", nil, "only models of these types (e.g. LORA,Checkpoint)")
+	downloadCommand.PersistentFlags().StringSliceVar(&flagBaseModels, "base-model", nil, "only versions for these base models (e.g. \"SDXL 1.0\")")
+	downloadCommand.PersistentFlags().StringVar(&flagFormat, "format", "", "preferred file format (e.g. SafeTensor, PickleTensor)")
+	downloadCommand.PersistentFlags().StringVar(&flagFiles, "files", "primary", "files of each version to fetch: primary, all, or type=VAE,Config")
+	downloadCommand.PersistentFlags().StringVar(&flagVersions, "versions", "latest", "versions of a model to fetch: latest, all, or the N newest")
 	downloadCommand.PersistentFlags().Int64VarP(&flagMaxChunkSize, "maxChunkSize", "s", 1024*1024*1024, "(deprecated, unused) kept for backward compatibility")
+	bindFlagToConfig(downloadCommand.PersistentFlags(), "downloadDir", "download-dir")
+	bindFlagToConfig(downloadCommand.PersistentFlags(), "numThreads", "threads")
+	downloadCommand.PersistentFlags().StringVar(&flagIfExists, "if-exists", "", "when the target file exists: skip, overwrite, rename or verify (default from config, else skip)")
+	bindFlagToConfig(downloadCommand.PersistentFlags(), "chunkSize", "chunk-size")
+	bindFlagToConfig(downloadCommand.PersistentFlags(), "if-exists", "if-exists")
+	ifExistsFlag = downloadCommand.PersistentFlags().Lookup("if-exists")
+	downloadCommand.RegisterFlagCompletionFunc("modelId", completeModelIDs)
+	downloadCommand.RegisterFlagCompletionFunc("numThreads", fixedCompletion(threadsAuto, "4", "8", "16"))
+	downloadCommand.RegisterFlagCompletionFunc("modelVersionId", completeVersionIDs)
+	downloadCommand.RegisterFlagCompletionFunc("downloadDir", completeDirs)
+	downloadCommand.RegisterFlagCompletionFunc("type", enumCompletion(modelTypes))
+	downloadCommand.RegisterFlagCompletionFunc("base-model", enumCompletion(baseModels))
+	downloadCommand.RegisterFlagCompletionFunc("format", fixedCompletion("SafeTensor", "PickleTensor", "GGUF", "Diffusers", "Core ML", "ONNX", "Other"))
+	downloadCommand.RegisterFlagCompletionFunc("files", fixedCompletion("primary", "all", "type="))
+	downloadCommand.RegisterFlagCompletionFunc("versions", fixedCompletion("latest", "all"))
+	downloadCommand.RegisterFlagCompletionFunc("if-exists", fixedCompletion(ifExistsPolicies...))
 	rootCmd.AddCommand(downloadCommand)
 }
 
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"civitai-model-downloader/dto"
+	"civitai-model-downloader/util"
+
+	"github.com/spf13/viper"
+)
+
+// TestFetchFileRedirectsOnce runs a download through the downloader the
+// way downloadFiles does and checks that only the probe reached the
+// redirect service, however the downloader probes and splits the file.
+func TestFetchFileRedirectsOnce(t *testing.T) {
+	content := strings.Repeat("0123456789", 50000)
+	var redirects atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/download/models/7", func(w http.ResponseWriter, r *http.Request) {
+		redirects.Add(1)
+		http.Redirect(w, r, "/cdn/model.safetensors?sig=abc", http.StatusFound)
+	})
+	mux.HandleFunc("/cdn/model.safetensors", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "model.safetensors", time.Time{}, strings.NewReader(content))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	old := flagChunkSizeStr
+	flagChunkSizeStr = "64K"
+	t.Cleanup(func() { flagChunkSizeStr = old })
+
+	url := srv.URL + "/api/download/models/7"
+	res, err := resolveDownload(context.Background(), url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Size != int64(len(content)) || res.Name != "model.safetensors" {
+		t.Fatalf("resolved = %+v", res)
+	}
+	out := filepath.Join(t.TempDir(), res.Name)
+	if err := fetchFile(context.Background(), url, out, res.Size, nil); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil || string(data) != content {
+		t.Fatalf("downloaded %d bytes, %v", len(data), err)
+	}
+	if n := redirects.Load(); n != 1 {
+		t.Errorf("redirect service hit %d times, want 1", n)
+	}
+}
+
+func TestVerifyDownload(t *testing.T) {
+	defer func(vc *viper.Viper) { appConfig = vc }(appConfig)
+	appConfig = newConfig()
+	path := filepath.Join(t.TempDir(), "model.safetensors.part")
+	write := func() {
+		if err := os.WriteFile(path, []byte("abc"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	good := &dto.FileHashes{SHA256: "BA7816BF8F01CFEA414140DE5DAE2223B00361A396177A9CB410FF61F20015AD"}
+	bad := &dto.FileHashes{SHA256: strings.Repeat("0", 64)}
+
+	write()
+	if err := verifyDownload(path, 5, nil, nil); err == nil || !util.FileExists(path) {
+		t.Fatalf("short file: %v, must fail and be kept for resuming", err)
+	}
+	if err := verifyDownload(path, 3, good, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, policy := range []string{"off", "warn", "strict"} {
+		appConfig.Set("verify", policy)
+		write()
+		err := verifyDownload(path, 3, bad, nil)
+		if err == nil {
+			t.Fatalf("%s: hash mismatch accepted", policy)
+		}
+		if kept := util.FileExists(path); kept != (policy != "strict") {
+			t.Errorf("%s: file kept = %v", policy, kept)
+		}
+	}
+}
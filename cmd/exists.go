@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"civitai-model-downloader/dto"
+	"civitai-model-downloader/i18n"
+	"civitai-model-downloader/log"
+	"civitai-model-downloader/util"
+
+	"github.com/spf13/pflag"
+)
+
+var flagIfExists string
+
+// ifExistsPolicies are the values of --if-exists and the if-exists
+// setting.
+var ifExistsPolicies = []string{"skip", "overwrite", "rename", "verify"}
+
+// ifExistsFlag is the --if-exists flag, set up with the download
+// command.
+var ifExistsFlag *pflag.Flag
+
+// ifExistsPolicy is --if-exists, or the if-exists setting for commands
+// without the flag (mirror).
+func ifExistsPolicy() (string, error) {
+	// Unless given on the command line, the flag holds the setting.
+	raw, source := flagIfExists, "--if-exists"
+	if ifExistsFlag == nil || !ifExistsFlag.Changed {
+		source = settingSource(appConfig, "if-exists")
+		if raw == "" {
+			raw = appConfig.GetString("if-exists")
+		}
+	}
+	p := strings.ToLower(strings.TrimSpace(raw))
+	if p == "" {
+		p = "skip"
+	}
+	for _, v := range ifExistsPolicies {
+		if p == v {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("invalid %s %q (want skip, overwrite, rename or verify)", source, raw)
+}
+
+// checkExisting applies --if-exists to a download about to be written
+// to outPath. It returns the path to download to, or "" when the file
+// already there is kept:
+//
+//   - skip keeps any existing file;
+//   - overwrite downloads again and replaces it;
+//   - rename downloads to the first free "name (N).ext";
+//   - verify keeps the file if its SHA256 matches hashes and downloads
+//     again otherwise. Without a published hash it behaves like skip.
+func checkExisting(outPath string, hashes *dto.FileHashes) (string, error) {
+	if !util.FileExists(outPath) {
+		return outPath, nil
+	}
+	policy, err := ifExistsPolicy()
+	if err != nil {
+		return "", err
+	}
+	switch policy {
+	case "overwrite":
+		return outPath, nil
+	case "rename":
+		return freePath(outPath), nil
+	case "verify":
+		if hashes == nil || hashes.SHA256 == "" {
+			break
+		}
+		sum, err := util.FileSHA256(outPath)
+		if err != nil {
+			return "", fmt.Errorf("verify existing %s: %w", outPath, err)
+		}
+		if !strings.EqualFold(sum, hashes.SHA256) {
+			log.Logger().Sugar().Warnf(i18n.T("%s exists but its sha256 does not match, downloading again"), outPath)
+			return outPath, nil
+		}
+	}
+	log.Logger().Sugar().Infof(i18n.T("%s already exists, skipping"), outPath)
+	return "", nil
+}
+
+// freePath returns path with " (N)" added before the extension, using
+// the lowest N not taken yet.
+func freePath(path string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for n := 1; ; n++ {
+		p := base + " (" + strconv.Itoa(n) + ")" + ext
+		if !util.FileExists(p) && !util.FileExists(p+partSuffix) {
+			return p
+		}
+	}
+}
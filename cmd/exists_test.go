@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"civitai-model-downloader/dto"
+)
+
+func TestCheckExisting(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "model.safetensors")
+	if err := os.WriteFile(path, []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	good := &dto.FileHashes{SHA256: "2CF24DBA5FB0A30E26E83B2AC5B9E29E1B161E5C1FA7425E73043362938B9824"}
+	bad := &dto.FileHashes{SHA256: "00"}
+	renamed := filepath.Join(dir, "model (1).safetensors")
+
+	for _, tc := range []struct {
+		policy string
+		hashes *dto.FileHashes
+		want   string
+	}{
+		{"skip", bad, ""},
+		{"overwrite", good, path},
+		{"rename", good, renamed},
+		{"verify", good, ""},
+		{"verify", bad, path},
+		{"verify", nil, ""},
+	} {
+		flagIfExists = tc.policy
+		got, err := checkExisting(path, tc.hashes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.policy, got, tc.want)
+		}
+	}
+	flagIfExists = ""
+
+	if got, _ := checkExisting(filepath.Join(dir, "new.bin"), nil); got != filepath.Join(dir, "new.bin") {
+		t.Errorf("missing file: got %q", got)
+	}
+}
+
+func TestIfExistsPolicySource(t *testing.T) {
+	old := appConfig
+	t.Cleanup(func() { appConfig = old })
+	t.Setenv("CVTCLI_IF_EXISTS", "Replace")
+	appConfig = newConfig()
+
+	_, err := ifExistsPolicy()
+	if err == nil || !strings.Contains(err.Error(), `CVTCLI_IF_EXISTS "Replace"`) {
+		t.Errorf("got %v, want the environment variable and its value", err)
+	}
+}
@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"civitai-model-downloader/dto"
+	"civitai-model-downloader/i18n"
+	"civitai-model-downloader/log"
+)
+
+// fileFilter selects files of a model version. The zero value picks the
+// primary file only.
+type fileFilter struct {
+	all   bool
+	types []string
+}
+
+// parseFileFilter parses the --files flag: "primary", "all" or
+// "type=VAE,Config". Type names are matched case-insensitively against
+// File.Type, and "pruned" / "full" also match File.Metadata.Size.
+func parseFileFilter(s string) (fileFilter, error) {
+	s = strings.TrimSpace(s)
+	switch strings.ToLower(s) {
+	case "", "primary":
+		return fileFilter{}, nil
+	case "all":
+		return fileFilter{all: true}, nil
+	}
+	list, ok := strings.CutPrefix(s, "type=")
+	if !ok || list == "" {
+		return fileFilter{}, fmt.Errorf("invalid --files %q (want all, primary or type=A,B)", s)
+	}
+	var f fileFilter
+	for _, t := range strings.Split(list, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			f.types = append(f.types, t)
+		}
+	}
+	return f, nil
+}
+
+func (f fileFilter) isPrimary() bool {
+	return !f.all && len(f.types) == 0
+}
+
+func (f fileFilter) match(file dto.File) bool {
+	if f.all {
+		return true
+	}
+	return slices.ContainsFunc(f.types, func(t string) bool {
+		if strings.EqualFold(t, file.Type) {
+			return true
+		}
+		return file.Metadata != nil && file.Metadata.Size != nil && strings.EqualFold(t, *file.Metadata.Size)
+	})
+}
+
+// selectFiles returns the files of a version matched by f. For the
+// primary filter the format preference of pickFile applies.
+func selectFiles(files []dto.File, f fileFilter, format string) []dto.File {
+	if f.isPrimary() {
+		if file, ok := pickFile(files, format); ok {
+			return []dto.File{file}
+		}
+		return nil
+	}
+	var out []dto.File
+	for _, file := range files {
+		if f.match(file) {
+			out = append(out, file)
+		}
+	}
+	return out
+}
+
+// parseVersionSpec parses the --versions flag into a limit for
+// selectVersions: "all" is 0, "latest" is 1 and N keeps the N newest.
+func parseVersionSpec(s string) (int, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "latest":
+		return 1, nil
+	case "all":
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid --versions %q (want all, latest or a positive number)", s)
+	}
+	return n, nil
+}
+
+// filesFailed reports the failures counted by downloadFiles as an
+// error, so the command exits non-zero.
+func filesFailed(failed, total int) error {
+	if failed == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d files failed", failed, total)
+}
+
+// downloadFiles fetches each file from its own DownloadURL and stores
+// it under the API-supplied file name, placed by info.outputPath. Files
+// kept by --if-exists are not even probed.
+// fallbackURL (the version's DownloadURL) is only used for a primary
+// file without its own URL. Failures are logged and counted so one
+// missing companion file doesn't abort the rest.
+func downloadFiles(ctx context.Context, files []dto.File, fallbackURL string, info nameInfo, outputDir string) (failed int, err error) {
+	for _, file := range files {
+		url := file.DownloadURL
+		if url == "" && file.Primary {
+			url = fallbackURL
+		}
+		if url == "" {
+			log.Logger().Sugar().Warnf(i18n.T("%s: no download URL"), file.Name)
+			failed++
+			continue
+		}
+		info.Filename = file.Name
+		outPath, err := info.outputPath(outputDir)
+		if err == nil {
+			outPath, err = checkExisting(outPath, file.Hashes)
+		}
+		if err != nil {
+			log.Logger().Sugar().Error(err)
+			failed++
+			continue
+		}
+		if outPath == "" {
+			continue
+		}
+		res, err := resolveDownload(ctx, url)
+		if err != nil {
+			if ctx.Err() != nil {
+				return failed, ctx.Err()
+			}
+			log.Logger().Sugar().Errorf("%s: %v", file.Name, diagnose(ctx, err, nil, url))
+			failed++
+			continue
+		}
+		size := res.Size
+		if size == 0 {
+			size = fileSize(file)
+		}
+		if err := fetchFile(ctx, url, outPath, size, file.Hashes); err != nil {
+			if ctx.Err() != nil {
+				return failed, ctx.Err()
+			}
+			log.Logger().Sugar().Errorf("%s: %v", file.Name, err)
+			failed++
+			continue
+		}
+		if err := recordDownload(outputDir, info, outPath); err != nil {
+			log.Logger().Sugar().Debugf("library: %v", err)
+		}
+		log.Logger().Sugar().Infof(i18n.T("download complete: %s"), outPath)
+	}
+	return failed, nil
+}
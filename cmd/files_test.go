@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"testing"
+
+	"civitai-model-downloader/dto"
+)
+
+func TestSelectFiles(t *testing.T) {
+	pruned := "pruned"
+	files := []dto.File{
+		{Name: "model.safetensors", Type: "Model", Primary: true},
+		{Name: "model-pruned.safetensors", Type: "Model", Metadata: &dto.FileMetadata{Size: &pruned}},
+		{Name: "vae.safetensors", Type: "VAE"},
+		{Name: "config.yaml", Type: "Config"},
+	}
+	cases := map[string][]string{
+		"primary":         {"model.safetensors"},
+		"all":             {"model.safetensors", "model-pruned.safetensors", "vae.safetensors", "config.yaml"},
+		"type=vae,Config": {"vae.safetensors", "config.yaml"},
+		"type=pruned":     {"model-pruned.safetensors"},
+	}
+	for spec, want := range cases {
+		f, err := parseFileFilter(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := selectFiles(files, f, "")
+		if len(got) != len(want) {
+			t.Fatalf("%s: got %v", spec, got)
+		}
+		for i := range want {
+			if got[i].Name != want[i] {
+				t.Fatalf("%s: got %s, want %s", spec, got[i].Name, want[i])
+			}
+		}
+	}
+	if _, err := parseFileFilter("type="); err == nil {
+		t.Fatal("expected error for empty type list")
+	}
+}
@@ -0,0 +1,210 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"civitai-model-downloader/api"
+	"civitai-model-downloader/dto"
+	"civitai-model-downloader/i18n"
+	"civitai-model-downloader/log"
+	"civitai-model-downloader/util"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	flagImagesModelId   string
+	flagImagesVersionId string
+	flagImagesOutputDir string
+	flagImagesNSFW      string
+	flagImagesLimit     int
+)
+
+var imagesCommand = &cobra.Command{
+	Use:   "images",
+	Short: "Download showcase images and their generation parameters",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		req := &dto.ImageRequest{}
+		switch {
+		case flagImagesVersionId != "":
+			value, err := resolveLibraryID(cmd, flagImagesVersionId, true)
+			if err != nil {
+				return fmt.Errorf("--modelVersionId: %w", err)
+			}
+			id, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid --modelVersionId %q", flagImagesVersionId)
+			}
+			req.ModelVersionID = &id
+		case flagImagesModelId != "":
+			value, err := resolveLibraryID(cmd, flagImagesModelId, false)
+			if err != nil {
+				return fmt.Errorf("--modelId: %w", err)
+			}
+			id, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid --modelId %q", flagImagesModelId)
+			}
+			req.ModelID = &id
+		default:
+			return fmt.Errorf("specify --modelVersionId or --modelId")
+		}
+
+		maxLevel := nsfwRank(flagImagesNSFW)
+		if maxLevel < 0 {
+			return fmt.Errorf("invalid --nsfw %q (want one of %s)", flagImagesNSFW, strings.Join(dto.NSFWLevels, ", "))
+		}
+		level := dto.NSFWLevels[maxLevel]
+		req.NSFW = &level
+
+		outputDir := flagImagesOutputDir
+		if outputDir == "" {
+			outputDir = "."
+		}
+		ctx, stop := jobContext()
+		defer stop()
+
+		saved, skipped, failed, err := saveImages(ctx, api.IterateImages(ctx, req), outputDir, maxLevel, flagImagesLimit)
+		if err != nil {
+			return err
+		}
+		log.Logger().Sugar().Infof(i18n.T("saved %d images to %s (%d filtered by nsfw level)"), saved, outputDir, skipped)
+		if failed > 0 {
+			return fmt.Errorf("%d of %d images failed", failed, saved+failed)
+		}
+		return nil
+	},
+}
+
+// saveImages saves the images of seq that nsfwAllowed lets through, at
+// most limit of them (all for 0). The cap counts saved images, so
+// filtered and failed ones don't use it up.
+func saveImages(ctx context.Context, seq iter.Seq2[dto.ImageItem, error], outputDir string, maxLevel, limit int) (saved, skipped, failed int, err error) {
+	for img, err := range seq {
+		if err != nil {
+			return saved, skipped, failed, err
+		}
+		if !nsfwAllowed(img, maxLevel) {
+			skipped++
+			continue
+		}
+		if err := saveImage(ctx, img, outputDir); err != nil {
+			if ctx.Err() != nil {
+				return saved, skipped, failed, ctx.Err()
+			}
+			log.Logger().Sugar().Errorf(i18n.T("image %d: %v"), img.ID, err)
+			failed++
+			continue
+		}
+		if saved++; limit > 0 && saved >= limit {
+			break
+		}
+	}
+	return saved, skipped, failed, nil
+}
+
+// nsfwRank is the position of level in dto.NSFWLevels, ignoring case,
+// or -1 for a level it doesn't know.
+func nsfwRank(level string) int {
+	return slices.IndexFunc(dto.NSFWLevels, func(l string) bool {
+		return strings.EqualFold(l, level)
+	})
+}
+
+// nsfwAllowed reports whether img is at most maxLevel. The API treats
+// nsfw as an upper bound, but older entries are not always tagged
+// consistently, so it is checked again; an image with a missing or
+// unknown level is left out.
+func nsfwAllowed(img dto.ImageItem, maxLevel int) bool {
+	rank := nsfwRank(img.NSFWLevel)
+	return rank >= 0 && rank <= maxLevel
+}
+
+// saveImage writes the image as <id>.<ext> and its metadata, including
+// the generation parameters, as <id>.json next to it. Images already
+// on disk are not fetched again.
+func saveImage(ctx context.Context, img dto.ImageItem, outputDir string) error {
+	base := filepath.Join(outputDir, strconv.Itoa(img.ID))
+	imgPath := base + imageExt(img)
+
+	meta, err := json.MarshalIndent(img, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(outputDir, 0777); err != nil {
+		return err
+	}
+	if err := os.WriteFile(base+".json", meta, 0644); err != nil {
+		return err
+	}
+	if util.FileExists(imgPath) {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", img.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := util.GetHttpClient().GetRawClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	f, err := util.CreateFile(imgPath + ".part")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		os.Remove(imgPath + ".part")
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(imgPath+".part", imgPath)
+}
+
+// imageExt takes the extension from the image URL, falling back to the
+// media type for URLs without one.
+func imageExt(img dto.ImageItem) string {
+	if u, err := url.Parse(img.URL); err == nil {
+		if ext := path.Ext(u.Path); ext != "" && len(ext) <= 5 && util.SanitizeFilename(ext[1:]) == ext[1:] {
+			return ext
+		}
+	}
+	if img.Type == "video" {
+		return ".mp4"
+	}
+	return ".jpeg"
+}
+
+func init() {
+	imagesCommand.Flags().StringVarP(&flagImagesVersionId, "modelVersionId", "v", "", "model version ID, or a version name from the local library")
+	imagesCommand.Flags().StringVarP(&flagImagesModelId, "modelId", "m", "", "model ID, or a model name from the local library")
+	imagesCommand.Flags().StringVarP(&flagImagesOutputDir, "downloadDir", "o", "", "output directory")
+	imagesCommand.Flags().StringVar(&flagImagesNSFW, "nsfw", "None", "highest NSFW level to include: None, Soft, Mature or X")
+	imagesCommand.Flags().IntVarP(&flagImagesLimit, "limit", "n", 0, "maximum number of images (0 = all)")
+	bindFlagToConfig(imagesCommand.Flags(), "downloadDir", "download-dir")
+	imagesCommand.RegisterFlagCompletionFunc("modelId", completeModelIDs)
+	imagesCommand.RegisterFlagCompletionFunc("modelVersionId", completeVersionIDs)
+	imagesCommand.RegisterFlagCompletionFunc("downloadDir", completeDirs)
+	imagesCommand.RegisterFlagCompletionFunc("nsfw", fixedCompletion(dto.NSFWLevels...))
+	rootCmd.AddCommand(imagesCommand)
+}
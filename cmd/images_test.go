@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"civitai-model-downloader/dto"
+)
+
+func TestNSFWAllowed(t *testing.T) {
+	maxLevel := nsfwRank("soft")
+	if maxLevel != 1 {
+		t.Fatalf("nsfwRank(soft) = %d", maxLevel)
+	}
+	for level, want := range map[string]bool{
+		"None":    true,
+		"Soft":    true,
+		"SOFT":    true,
+		"Mature":  false,
+		"X":       false,
+		"":        false,
+		"Unknown": false,
+	} {
+		if got := nsfwAllowed(dto.ImageItem{NSFWLevel: level}, maxLevel); got != want {
+			t.Errorf("level %q: got %v, want %v", level, got, want)
+		}
+	}
+}
+
+func TestSaveImage(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Write([]byte("image data"))
+	}))
+	defer srv.Close()
+
+	dir := filepath.Join(t.TempDir(), "images")
+	meta := json.RawMessage(`{"prompt":"a cat","seed":42}`)
+	img := dto.ImageItem{ID: 5, URL: srv.URL + "/x/cat.png", NSFWLevel: "None", Meta: &meta}
+	for range 2 {
+		if err := saveImage(context.Background(), img, dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := hits.Load(); n != 1 {
+		t.Errorf("image fetched %d times, want 1", n)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "5.png"))
+	if err != nil || string(data) != "image data" {
+		t.Fatalf("image: %q, %v", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "5.png.part")); !os.IsNotExist(err) {
+		t.Error(".part left behind")
+	}
+	var saved struct {
+		ID   int `json:"id"`
+		Meta struct {
+			Prompt string `json:"prompt"`
+			Seed   int    `json:"seed"`
+		} `json:"meta"`
+	}
+	data, err = os.ReadFile(filepath.Join(dir, "5.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.ID != 5 || saved.Meta.Prompt != "a cat" || saved.Meta.Seed != 42 {
+		t.Errorf("metadata not kept: %s", data)
+	}
+}
+
+func TestSaveImagesLimitCountsSaved(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken.png" {
+			http.Error(w, "gone", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("image data"))
+	}))
+	defer srv.Close()
+
+	images := []dto.ImageItem{
+		{ID: 1, URL: srv.URL + "/1.png", NSFWLevel: "X"},
+		{ID: 2, URL: srv.URL + "/2.png", NSFWLevel: "Mature"},
+		{ID: 3, URL: srv.URL + "/broken.png", NSFWLevel: "None"},
+		{ID: 4, URL: srv.URL + "/4.png", NSFWLevel: "None"},
+		{ID: 5, URL: srv.URL + "/5.png", NSFWLevel: "Soft"},
+		{ID: 6, URL: srv.URL + "/6.png", NSFWLevel: "None"},
+	}
+	seq := func(yield func(dto.ImageItem, error) bool) {
+		for _, img := range images {
+			if !yield(img, nil) {
+				return
+			}
+		}
+	}
+	dir := t.TempDir()
+	saved, skipped, failed, err := saveImages(context.Background(), seq, dir, nsfwRank("soft"), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved != 2 || skipped != 2 || failed != 1 {
+		t.Errorf("saved %d, skipped %d, failed %d; want 2, 2, 1", saved, skipped, failed)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "6.png")); !os.IsNotExist(err) {
+		t.Error("image past the limit was saved")
+	}
+}
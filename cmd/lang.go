@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"strings"
+
+	"civitai-model-downloader/i18n"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var flagLang string
+
+// detectLang picks the message language before cobra runs, so help
+// output is translated too: --lang, then the lang setting (config file
+// or CVTCLI_LANG), then LC_ALL / LC_MESSAGES / LANG.
+func detectLang(args []string) string {
+	for i, a := range args {
+		if v, ok := strings.CutPrefix(a, "--lang="); ok {
+			return v
+		}
+		if a == "--lang" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	vc := newConfig()
+	if err := loadConfigFile(vc); err == nil {
+		if l := vc.GetString("lang"); l != "" {
+			return l
+		}
+	}
+	return i18n.FromEnv()
+}
+
+// usageHeadings are the fixed strings of cobra's usage template. Each
+// starts a line of the template and is only replaced there, so "Flags:"
+// doesn't match inside "Global Flags:".
+var usageHeadings = []string{
+	"Usage:",
+	"Aliases:",
+	"Examples:",
+	"Available Commands:",
+	"Additional Commands:",
+	"Flags:",
+	"Global Flags:",
+	"Additional help topics:",
+	`Use "{{.CommandPath}} [command] --help" for more information about a command.`,
+}
+
+// localizeCommand translates the help text of c and all its
+// subcommands: short descriptions, flag usages and the usage template.
+func localizeCommand(c *cobra.Command) {
+	if i18n.Lang() == i18n.English {
+		return
+	}
+	lines := strings.Split(c.UsageTemplate(), "\n")
+	for i, line := range lines {
+		for _, h := range usageHeadings {
+			if rest, ok := strings.CutPrefix(line, h); ok {
+				lines[i] = i18n.T(h) + rest
+				break
+			}
+		}
+	}
+	c.SetUsageTemplate(strings.Join(lines, "\n"))
+	walkCommands(c, func(sub *cobra.Command) {
+		sub.Short = i18n.T(sub.Short)
+		sub.LocalFlags().VisitAll(func(f *pflag.Flag) { f.Usage = i18n.T(f.Usage) })
+	})
+}
+
+func walkCommands(c *cobra.Command, fn func(*cobra.Command)) {
+	fn(c)
+	for _, sub := range c.Commands() {
+		walkCommands(sub, fn)
+	}
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&flagLang, "lang", "", "language of messages and help: en or zh (default from LANG)")
+	rootCmd.RegisterFlagCompletionFunc("lang", fixedCompletion(i18n.English, i18n.Chinese))
+}
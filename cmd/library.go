@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/spf13/cobra"
+)
+
+// libraryEntry records one downloaded file so later commands (shell
+// completion in particular) can refer to models by name.
+type libraryEntry struct {
+	ModelID   int       `json:"modelId"`
+	VersionID int       `json:"versionId"`
+	Model     string    `json:"model"`
+	Version   string    `json:"version"`
+	Path      string    `json:"path"`
+	Time      time.Time `json:"time"`
+}
+
+func libraryPath(outputDir string) string {
+	return filepath.Join(outputDir, stateDirName, "library.json")
+}
+
+// libraryDir is the download dir whose library a command's --modelId
+// and --modelVersionId refer to: its --downloadDir, else the
+// configured one.
+func libraryDir(cmd *cobra.Command) string {
+	if f := cmd.Flag("downloadDir"); f != nil && f.Value.String() != "" {
+		return f.Value.String()
+	}
+	if dir := appConfig.GetString("download-dir"); dir != "" {
+		return dir
+	}
+	return "."
+}
+
+// libraryKey is the form of a library name that shell completion
+// offers and resolveLibraryID accepts in place of an ID: lower case,
+// with every run of other characters than letters and digits turned
+// into a dash, e.g. "realistic-vision" for "Realistic Vision".
+func libraryKey(name string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), "-")
+}
+
+// versionName names a library entry's version for completion.
+func versionName(e libraryEntry) string {
+	return e.Model + " / " + e.Version
+}
+
+// resolveLibraryID turns a --modelId (or, with version set,
+// --modelVersionId) value into an ID. IDs pass through; anything else
+// is looked up as a libraryKey in the library of cmd's download dir.
+func resolveLibraryID(cmd *cobra.Command, value string, version bool) (string, error) {
+	if value == "" || isDigits(value) {
+		return value, nil
+	}
+	dir := libraryDir(cmd)
+	entries, err := loadLibrary(dir)
+	if err != nil {
+		return "", err
+	}
+	key := strings.ToLower(value)
+	for _, e := range entries {
+		if version && e.VersionID != 0 && libraryKey(versionName(e)) == key {
+			return fmt.Sprint(e.VersionID), nil
+		}
+		if !version && e.ModelID != 0 && libraryKey(e.Model) == key {
+			return fmt.Sprint(e.ModelID), nil
+		}
+	}
+	return "", fmt.Errorf("%q is neither an ID nor a name in the library of %s", value, dir)
+}
+
+// loadLibrary reads the library index of outputDir. A missing index is
+// an empty library.
+func loadLibrary(outputDir string) ([]libraryEntry, error) {
+	data, err := os.ReadFile(libraryPath(outputDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var entries []libraryEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// recordDownload adds a finished download to the library index of
+// outputDir, replacing an older entry for the same path. Downloads from
+// a bare URL carry no IDs and are not recorded.
+func recordDownload(outputDir string, info nameInfo, path string) error {
+	if info.ModelID == 0 && info.VersionID == 0 {
+		return nil
+	}
+	entries, err := loadLibrary(outputDir)
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(outputDir, path); err == nil {
+		path = rel
+	}
+	entry := libraryEntry{
+		ModelID:   info.ModelID,
+		VersionID: info.VersionID,
+		Model:     info.Model,
+		Version:   info.Version,
+		Path:      path,
+		Time:      time.Now().UTC(),
+	}
+	replaced := false
+	for i := range entries {
+		if entries[i].Path == path {
+			entries[i] = entry
+			replaced = true
+		}
+	}
+	if !replaced {
+		entries = append(entries, entry)
+	}
+	return saveJSON(libraryPath(outputDir), entries)
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"civitai-model-downloader/i18n"
+	"civitai-model-downloader/log"
+
+	"go.uber.org/zap/zapcore"
+)
+
+var (
+	flagLogLevel  string
+	flagLogFormat string
+	flagLogFile   string
+	flagQuiet     bool
+	flagVerbose   bool
+
+	// loggerAnnounced is set once the debug banner has been logged.
+	loggerAnnounced bool
+)
+
+// configureLogging applies the logging flags. It runs once before the
+// config file is read, so --log-level governs the config messages too,
+// and again after the config values have been copied into the flags.
+func configureLogging() error {
+	if flagQuiet && flagVerbose {
+		return fmt.Errorf("--quiet and --verbose are mutually exclusive")
+	}
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		log.DisableColor()
+	}
+	opts := log.Options{
+		Level:  flagLogLevel,
+		Format: flagLogFormat,
+		File:   flagLogFile,
+	}
+	switch {
+	case flagQuiet:
+		opts.Level = "error"
+	case flagVerbose:
+		opts.Level = "debug"
+	}
+	if err := log.Configure(opts); err != nil {
+		return err
+	}
+	// Only debug logging shows the banner, at whichever call turns it on.
+	if !loggerAnnounced && log.Logger().Core().Enabled(zapcore.DebugLevel) {
+		loggerAnnounced = true
+		log.Logger().Debug(i18n.T("logger initialized"))
+	}
+	return nil
+}
+
+func init() {
+	flags := rootCmd.PersistentFlags()
+	flags.StringVar(&flagLogLevel, "log-level", "info", "log level: debug, info, warn or error")
+	flags.StringVar(&flagLogFormat, "log-format", log.FormatConsole, "log format: console, json or logfmt")
+	flags.StringVar(&flagLogFile, "log-file", "", "also write logs to this file, rotated at 50 MB")
+	flags.BoolVarP(&flagQuiet, "quiet", "q", false, "only log errors")
+	flags.BoolVar(&flagVerbose, "verbose", false, "log debug messages")
+	bindFlagToConfig(flags, "log-level", "log-level")
+	bindFlagToConfig(flags, "log-format", "log-format")
+	bindFlagToConfig(flags, "log-file", "log-file")
+	rootCmd.RegisterFlagCompletionFunc("log-level", fixedCompletion("debug", "info", "warn", "error"))
+	rootCmd.RegisterFlagCompletionFunc("log-format", fixedCompletion(log.FormatConsole, log.FormatJSON, log.FormatLogfmt))
+}
@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"civitai-model-downloader/dto"
+	"civitai-model-downloader/i18n"
+	"civitai-model-downloader/log"
+	"civitai-model-downloader/util"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	flagMirrorSchedule time.Duration
+)
+
+// mirrorSpec is a saved search loaded from a YAML file, e.g.
+//
+//	name: sdxl-loras-weekly
+//	dir: /srv/models/loras
+//	query:
+//	  types: [LORA]
+//	  baseModels: ["SDXL 1.0"]
+//	  sort: Most Downloaded
+//	  period: Week
+//	min-downloads: 500
+//	min-rating: 90
+//	max-items: 100
+//
+// The query block maps onto dto.ModelRequest; the remaining keys filter
+// the results client side. max-items counts the models that pass those
+// filters.
+type mirrorSpec struct {
+	Name         string           `mapstructure:"name"`
+	Dir          string           `mapstructure:"dir"`
+	Query        dto.ModelRequest `mapstructure:"query"`
+	MinDownloads int              `mapstructure:"min-downloads"`
+	// MinRating is the minimum share of thumbs-up reviews, in percent.
+	MinRating   float64  `mapstructure:"min-rating"`
+	BaseModels  []string `mapstructure:"base-models"`
+	AllVersions bool     `mapstructure:"all-versions"`
+	Format      string   `mapstructure:"format"`
+	MaxItems    int      `mapstructure:"max-items"`
+}
+
+var mirrorCommand = &cobra.Command{
+	Use:               "mirror <spec.yaml>...",
+	Short:             "Keep local directories in sync with saved searches",
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeSpecFiles,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		specs := make([]*mirrorSpec, 0, len(args))
+		for _, path := range args {
+			spec, err := loadMirrorSpec(path)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			specs = append(specs, spec)
+		}
+
+		if flagMirrorSchedule <= 0 {
+			ctx, stop := jobContext()
+			defer stop()
+			return mirrorRound(ctx, specs)
+		}
+
+		// On a schedule, --timeout bounds each run rather than the
+		// whole daemon.
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		for {
+			runCtx, cancel := ctx, context.CancelFunc(func() {})
+			if flagTimeout > 0 {
+				runCtx, cancel = context.WithTimeout(ctx, flagTimeout)
+			}
+			mirrorRound(runCtx, specs)
+			if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+				log.Logger().Sugar().Errorf(i18n.T("mirror run timed out after %s"), flagTimeout)
+			}
+			cancel()
+			if ctx.Err() != nil {
+				return nil
+			}
+			log.Logger().Sugar().Infof(i18n.T("next mirror run at %s"), time.Now().Add(flagMirrorSchedule).Format(time.DateTime))
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(flagMirrorSchedule):
+			}
+		}
+	},
+}
+
+// mirrorRound runs every spec once, logging failures, and returns an
+// error naming the specs that failed. It stops early once ctx is done.
+// Only a one-shot run turns the error into its exit status; on a
+// schedule the next round simply tries again.
+func mirrorRound(ctx context.Context, specs []*mirrorSpec) error {
+	var failed []string
+	for _, spec := range specs {
+		if err := runMirror(ctx, spec); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			log.Logger().Sugar().Errorf(i18n.T("mirror %s: %v"), spec.Name, err)
+			failed = append(failed, spec.Name)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d mirrors failed: %s", len(failed), len(specs), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+func loadMirrorSpec(path string) (*mirrorSpec, error) {
+	vc := viper.New()
+	vc.SetConfigFile(path)
+	if err := vc.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	spec := &mirrorSpec{}
+	if err := vc.Unmarshal(spec); err != nil {
+		return nil, err
+	}
+	if spec.Name == "" {
+		spec.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if spec.Dir == "" {
+		spec.Dir = "."
+	}
+	// Cursor and page are managed by the iterator.
+	spec.Query.Cursor = nil
+	spec.Query.Page = nil
+	return spec, nil
+}
+
+// runMirror downloads everything new that matches spec. Versions seen
+// by earlier runs are tracked in the mirror's state file.
+func runMirror(ctx context.Context, spec *mirrorSpec) error {
+	state, err := loadSeenState(statePath(spec.Dir, "mirror-"+util.SanitizeFilename(spec.Name)))
+	if err != nil {
+		return fmt.Errorf("load state: %w", err)
+	}
+	baseModels := spec.BaseModels
+	if len(baseModels) == 0 {
+		baseModels = spec.Query.BaseModels
+	}
+
+	log.Logger().Sugar().Infof(i18n.T("mirror %s: syncing into %s"), spec.Name, spec.Dir)
+	versions := 1
+	if spec.AllVersions {
+		versions = 0
+	}
+	stats, err := syncModels(ctx, &spec.Query, spec.Dir, state, syncOptions{
+		BaseModels: baseModels,
+		Versions:   versions,
+		Format:     spec.Format,
+		MaxItems:   spec.MaxItems,
+		Accept:     spec.accept,
+	})
+	if err != nil {
+		return err
+	}
+	log.Logger().Sugar().Infof(i18n.T("mirror %s: %d downloaded, %d already present, %d failed"), spec.Name, stats.Fetched, stats.Skipped, stats.Failed)
+	return stats.err()
+}
+
+func (s *mirrorSpec) accept(m dto.ModelItem) bool {
+	if s.MinDownloads <= 0 && s.MinRating <= 0 {
+		return true
+	}
+	if m.Stats == nil {
+		return false
+	}
+	if m.Stats.DownloadCount < s.MinDownloads {
+		return false
+	}
+	if s.MinRating > 0 {
+		votes := m.Stats.ThumbsUpCount + m.Stats.ThumbsDownCount
+		if votes == 0 || float64(m.Stats.ThumbsUpCount)*100/float64(votes) < s.MinRating {
+			return false
+		}
+	}
+	return true
+}
+
+func init() {
+	mirrorCommand.Flags().DurationVar(&flagMirrorSchedule, "schedule", 0, "rerun every interval (e.g. 6h); 0 runs once")
+	rootCmd.AddCommand(mirrorCommand)
+}
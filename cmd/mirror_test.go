@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"civitai-model-downloader/dto"
+)
+
+func TestLoadMirrorSpec(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "weekly.yaml")
+	spec := `
+query:
+  types: [LORA]
+  baseModels: ["SDXL 1.0"]
+  sort: Most Downloaded
+  limit: 50
+min-downloads: 500
+min-rating: 90
+`
+	if err := os.WriteFile(path, []byte(spec), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := loadMirrorSpec(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "weekly" || s.Query.Sort == nil || *s.Query.Sort != "Most Downloaded" {
+		t.Fatalf("unexpected spec: %+v", s)
+	}
+	if len(s.Query.BaseModels) != 1 || s.Query.Limit == nil || *s.Query.Limit != 50 {
+		t.Fatalf("query not decoded: %+v", s.Query)
+	}
+
+	liked := dto.ModelItem{Stats: &dto.ModelStats{DownloadCount: 1000, ThumbsUpCount: 95, ThumbsDownCount: 5}}
+	disliked := dto.ModelItem{Stats: &dto.ModelStats{DownloadCount: 1000, ThumbsUpCount: 50, ThumbsDownCount: 50}}
+	if !s.accept(liked) || s.accept(disliked) {
+		t.Fatal("rating filter not applied")
+	}
+}
+
+func TestMirrorRoundReportsFailures(t *testing.T) {
+	dir := t.TempDir()
+	spec := &mirrorSpec{Name: "broken", Dir: dir}
+	path := statePath(dir, "mirror-broken")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := mirrorRound(context.Background(), []*mirrorSpec{spec}); err == nil {
+		t.Fatal("a failed mirror must make the round fail")
+	}
+}
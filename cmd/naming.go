@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"civitai-model-downloader/dto"
+	"civitai-model-downloader/util"
+)
+
+// nameInfo is what the layout and naming settings know about a file.
+// Fields are empty when the download started from a bare URL.
+type nameInfo struct {
+	Filename  string
+	Model     string
+	Version   string
+	Type      string
+	BaseModel string
+	ModelID   int
+	VersionID int
+}
+
+func compactNameInfo(model dto.ModelItem, v dto.ModelVersionCompact) nameInfo {
+	return nameInfo{
+		Model:     model.Name,
+		Version:   v.Name,
+		Type:      model.Type,
+		BaseModel: v.BaseModel,
+		ModelID:   model.ID,
+		VersionID: v.ID,
+	}
+}
+
+func fullNameInfo(v *dto.ModelVersionFull) nameInfo {
+	n := nameInfo{
+		Version:   v.Name,
+		BaseModel: v.BaseModel,
+		ModelID:   v.ModelID,
+		VersionID: v.ID,
+	}
+	if v.Model != nil {
+		n.Model = v.Model.Name
+		n.Type = v.Model.Type
+	}
+	return n
+}
+
+// outputPath places the file under outputDir according to the layout
+// and naming settings. Every name in nameInfo comes from the server
+// or the API and is sanitized to a single path element before use; a
+// path that still ends up outside outputDir is an error.
+func (n nameInfo) outputPath(outputDir string) (string, error) {
+	dir := outputDir
+	if appConfig.GetString("layout") == "by-type" && n.Type != "" {
+		dir = filepath.Join(dir, safeName(n.Type))
+		if n.BaseModel != "" {
+			dir = filepath.Join(dir, safeName(n.BaseModel))
+		}
+	}
+	path := filepath.Join(dir, n.expand(appConfig.GetString("naming")))
+	if err := util.WithinDir(outputDir, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// safeName sanitizes a non-empty name; empty stays empty so templates
+// can still tell a missing value.
+func safeName(s string) string {
+	if s == "" {
+		return ""
+	}
+	return util.SanitizeFilename(s)
+}
+
+// expand fills the naming template. Templates that expand to nothing
+// fall back to the original file name.
+func (n nameInfo) expand(tmpl string) string {
+	filename := safeName(n.Filename)
+	ext := filepath.Ext(filename)
+	r := strings.NewReplacer(
+		"{filename}", filename,
+		"{name}", strings.TrimSuffix(filename, ext),
+		"{ext}", strings.TrimPrefix(ext, "."),
+		"{model}", safeName(n.Model),
+		"{version}", safeName(n.Version),
+		"{modelId}", itoaNonZero(n.ModelID),
+		"{versionId}", itoaNonZero(n.VersionID),
+		"{type}", safeName(n.Type),
+		"{baseModel}", safeName(n.BaseModel),
+	)
+	name := strings.TrimSpace(r.Replace(tmpl))
+	if name == "" || name == "."+strings.TrimPrefix(ext, ".") {
+		return filename
+	}
+	return name
+}
+
+func itoaNonZero(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
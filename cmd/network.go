@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"civitai-model-downloader/i18n"
+	"civitai-model-downloader/log"
+	"civitai-model-downloader/util"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var flagInsecure bool
+
+// configureNetwork applies the proxy and TLS settings of the active
+// profile to the shared HTTP client, which carries the API requests as
+// well as the downloader's chunk requests.
+func configureNetwork(vc *viper.Viper) error {
+	if err := util.SetProxy(vc.GetString("proxy")); err != nil {
+		return err
+	}
+	if flagInsecure {
+		log.Logger().Warn(i18n.T("TLS certificate verification is disabled (--insecure)"))
+	}
+	return util.SetTLS(util.TLSOptions{
+		CAFile:   vc.GetString("ca-bundle"),
+		CertFile: vc.GetString("client-cert"),
+		KeyFile:  vc.GetString("client-key"),
+		Insecure: flagInsecure,
+	})
+}
+
+// configureTransport sizes the shared client's connection pool for
+// concurrency chunk requests per host and applies the connection
+// settings of the active profile.
+func configureTransport(vc *viper.Viper, concurrency int) error {
+	o := util.TransportOptions{Concurrency: concurrency, HTTP2: vc.GetBool("http2")}
+	for key, d := range map[string]*time.Duration{
+		"dial-timeout":            &o.DialTimeout,
+		"tls-timeout":             &o.TLSHandshakeTimeout,
+		"response-header-timeout": &o.ResponseHeaderTimeout,
+		"keep-alive":              &o.KeepAlive,
+	} {
+		v := vc.GetString(key)
+		if v == "" {
+			continue
+		}
+		var err error
+		if *d, err = time.ParseDuration(v); err != nil || *d < 0 {
+			return fmt.Errorf("invalid %s %q", key, v)
+		}
+	}
+	if v := vc.GetString("tcp-buffer"); v != "" {
+		n := parseChunkSize(v)
+		if n <= 0 || n > 1<<30 {
+			return fmt.Errorf("invalid tcp-buffer %q", v)
+		}
+		o.SocketBuffer = int(n)
+	}
+	util.SetTransport(o)
+	return nil
+}
+
+// transportConcurrency is the number of chunk requests per host that
+// cmd sends at once.
+func transportConcurrency(cmd *cobra.Command) int {
+	if cmd == benchCommand {
+		return benchConcurrency()
+	}
+	return downloadConcurrency()
+}
+
+// downloadConcurrency is the number of chunk requests per host that
+// --numThreads allows.
+func downloadConcurrency() int {
+	n, _, err := threadSetting()
+	if err != nil {
+		return util.DefaultTransportOptions.Concurrency
+	}
+	return n
+}
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&flagInsecure, "insecure", false, "skip TLS certificate verification")
+	bindFlagToConfig(rootCmd.PersistentFlags(), "insecure", "insecure")
+}
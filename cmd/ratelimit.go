@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"civitai-model-downloader/util"
+
+	"github.com/spf13/viper"
+)
+
+var flagLimitRate string
+
+// rateWindow overrides the bandwidth cap between two times of day.
+// Windows may wrap midnight (22:00-07:00).
+type rateWindow struct {
+	from, to time.Duration // since midnight
+	rate     int64
+}
+
+func (w rateWindow) contains(t time.Time) bool {
+	d := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+	if w.from <= w.to {
+		return d >= w.from && d < w.to
+	}
+	return d >= w.from || d < w.to
+}
+
+// parseRate parses a bandwidth such as "20M", "512K" or "5MB/s" into
+// bytes per second. Empty and "0" mean unlimited.
+func parseRate(s string) (int64, error) {
+	s = strings.TrimSuffix(strings.TrimSpace(s), "/s")
+	if s == "" || s == "0" {
+		return 0, nil
+	}
+	n := parseChunkSize(s)
+	if n <= 0 {
+		return 0, fmt.Errorf("invalid rate %q (want e.g. 512K, 20M or 0 for unlimited)", s)
+	}
+	return n, nil
+}
+
+// parseRateSchedule parses the rate-schedule setting: comma-separated
+// "HH:MM-HH:MM=RATE" windows, e.g. "22:00-07:00=0, 12:00-13:00=50M".
+func parseRateSchedule(s string) ([]rateWindow, error) {
+	var windows []rateWindow
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		span, rate, ok := strings.Cut(part, "=")
+		from, to, ok2 := strings.Cut(span, "-")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("invalid rate-schedule window %q (want HH:MM-HH:MM=RATE)", part)
+		}
+		var w rateWindow
+		var err error
+		if w.from, err = parseTimeOfDay(from); err != nil {
+			return nil, err
+		}
+		if w.to, err = parseTimeOfDay(to); err != nil {
+			return nil, err
+		}
+		if w.rate, err = parseRate(rate); err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q (want HH:MM)", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// rateAt is the cap in effect at t: that of the first window holding
+// t, else def.
+func rateAt(windows []rateWindow, def int64, t time.Time) int64 {
+	for _, w := range windows {
+		if w.contains(t) {
+			return w.rate
+		}
+	}
+	return def
+}
+
+// configureRateLimit applies --limit-rate and the rate-schedule setting
+// to the shared HTTP client. The cap is global: it is shared by every
+// chunk worker and every file of a batch or mirror run.
+func configureRateLimit(vc *viper.Viper) error {
+	def, err := parseRate(flagLimitRate)
+	if err != nil {
+		return fmt.Errorf("--limit-rate: %w", err)
+	}
+	windows, err := parseRateSchedule(vc.GetString("rate-schedule"))
+	if err != nil {
+		return err
+	}
+	if def == 0 && len(windows) == 0 {
+		util.SetRateLimit(nil)
+		return nil
+	}
+	util.SetRateLimit(func(t time.Time) int64 {
+		return rateAt(windows, def, t)
+	})
+	return nil
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&flagLimitRate, "limit-rate", "", "total bandwidth cap, e.g. 20M (per second); empty is unlimited")
+	bindFlagToConfig(rootCmd.PersistentFlags(), "limit-rate", "rate-limit")
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateSchedule(t *testing.T) {
+	windows, err := parseRateSchedule("22:00-07:00=0, 12:00-13:00=50M")
+	if err != nil {
+		t.Fatal(err)
+	}
+	def, err := parseRate("5MB/s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(hhmm string) time.Time {
+		tt, _ := time.Parse("15:04", hhmm)
+		return tt
+	}
+	for _, tc := range []struct {
+		at   string
+		want int64
+	}{
+		{"23:30", 0},
+		{"03:00", 0},
+		{"07:00", 5 << 20},
+		{"12:30", 50 << 20},
+		{"13:00", 5 << 20},
+		{"21:59", 5 << 20},
+	} {
+		if got := rateAt(windows, def, at(tc.at)); got != tc.want {
+			t.Errorf("%s: got %d, want %d", tc.at, got, tc.want)
+		}
+	}
+
+	for _, bad := range []string{"22:00=0", "25:00-07:00=0", "22:00-07:00=fast"} {
+		if _, err := parseRateSchedule(bad); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
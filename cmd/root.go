@@ -1,87 +1,210 @@
 package cmd
 
 import (
+	"civitai-model-downloader/i18n"
 	"civitai-model-downloader/log"
 	"civitai-model-downloader/util"
+	"errors"
 	"fmt"
 	"os"
-	"os/user"
+	"path/filepath"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"golang.org/x/term"
 )
 
 var ConfigFilePath string
 var rootCmd = cobra.Command{
-	Use: "cvtcli",
+	Use:   "cvtcli",
+	Short: "Download models, images and whole catalogs from Civitai",
 	Run: func(cmd *cobra.Command, args []string) {
 
 	},
+	SilenceErrors: true,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
-		vc, err := initConfig()
+		// Flags parsed fine; later errors are runtime failures, not
+		// usage mistakes.
+		cmd.SilenceUsage = true
+		if isCompletionRequest(cmd) {
+			return prepareCompletion()
+		}
+		if err := configureLogging(); err != nil {
+			return err
+		}
+		vc, fresh, err := initConfig()
 		if err != nil {
 			return err
 		}
-		token := vc.GetString("api-key")
-		util.AuthHeader = map[string]string{"Authorization": "Bearer " + token}
+		if err := applyProfile(vc, true); err != nil {
+			return err
+		}
+		appConfig = vc
+		if err := applyConfigToFlags(cmd, vc); err != nil {
+			return err
+		}
+		if err := configureLogging(); err != nil {
+			return err
+		}
+		if err := configureNetwork(vc); err != nil {
+			return err
+		}
+		if err := configureTimeouts(vc); err != nil {
+			return err
+		}
+		if err := configureTransport(vc, transportConcurrency(cmd)); err != nil {
+			return err
+		}
+		if err := configureRateLimit(vc); err != nil {
+			return err
+		}
+		// The token check goes through the configured proxy and
+		// timeouts, so it runs only now.
+		if fresh {
+			firstRunLogin(vc)
+		}
+		util.SetTokenSource(func() string {
+			token, _ := resolveToken(vc)
+			return token
+		})
 		return nil
 	},
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	i18n.SetLang(detectLang(os.Args[1:]))
+	// Add cobra's completion command now rather than in
+	// rootCmd.Execute, so its help is translated along with the rest.
+	rootCmd.InitDefaultCompletionCmd()
+	localizeCommand(&rootCmd)
+	err := rootCmd.Execute()
+	if jobTimedOut.Load() {
+		err = &downloadError{Code: exitTimeout, Msg: fmt.Sprintf(i18n.T("timed out after %s"), flagTimeout), Err: err}
+	}
+	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		var dlErr *downloadError
+		if errors.As(err, &dlErr) {
+			os.Exit(dlErr.Code)
+		}
+		os.Exit(exitFailure)
 	}
 
 }
 
-func initConfig() (*viper.Viper, error) {
-	vc := viper.New()
+// loadConfigOnly is the pre-run hook of the config and auth commands,
+// which must work before a valid config or profile exists: it loads
+// what is there and sets up logging, nothing else.
+func loadConfigOnly(cmd *cobra.Command) error {
+	cmd.SilenceUsage = true
+	if err := loadConfigFile(appConfig); err != nil {
+		return err
+	}
+	if err := applyConfigToFlags(cmd, appConfig); err != nil {
+		return err
+	}
+	return configureLogging()
+}
+
+// initConfig loads the config file. fresh reports a first run, on
+// which the default config has just been created.
+func initConfig() (vc *viper.Viper, fresh bool, err error) {
+	vc = newConfig()
 	if ConfigFilePath != "" {
 		vc.SetConfigFile(ConfigFilePath)
 		err := vc.ReadInConfig()
 		if err != nil {
-			return nil, err
+			return nil, false, err
 		}
-		log.Logger().Info("已加载配置文件：" + vc.ConfigFileUsed())
-		return vc, nil
+		log.Logger().Sugar().Debugf(i18n.T("loaded config file %s"), vc.ConfigFileUsed())
+		return vc, false, nil
+	}
+
+	_, err = os.Stat(DefaultConfigPath())
+	if os.IsNotExist(err) {
+		firstRun()
+		return vc, true, nil
+	} else if err != nil {
+		// Unreadable config dir: run on defaults and the environment.
+		log.Logger().Sugar().Warnf(i18n.T("cannot read %s: %v"), DefaultConfigPath(), err)
+		return vc, false, nil
+	}
+
+	vc.SetConfigFile(DefaultConfigPath())
+	err = vc.ReadInConfig()
+	if err != nil {
+		return nil, false, err
+	}
+	log.Logger().Sugar().Debugf(i18n.T("loaded config file %s"), vc.ConfigFileUsed())
+	return vc, false, nil
+}
+
+// firstRun sets up a machine without a config file. Failing to write
+// the config (read-only home in containers) is not an error: the
+// command still runs on defaults and the environment.
+func firstRun() {
+	path := DefaultConfigPath()
+	if err := writeDefaultConfig(path); err != nil {
+		log.Logger().Sugar().Debugf(i18n.T("cannot create %s, using built-in defaults: %v"), path, err)
 	} else {
-		_, err := os.Stat(DefaultConfigPath())
-		if err != nil {
-			if os.IsNotExist(err) {
-				file, err := util.CreateFile(DefaultConfigPath())
-				if err != nil {
-					return nil, err
-				}
-				file.Close()
-				vc.Set("api-key", "")
-				err = vc.WriteConfigAs(DefaultConfigPath())
-				if err != nil {
-					return nil, err
-				}
-				log.Logger().Info("配置文件已初始化，请前往 " + DefaultConfigPath() + " 修改api-key")
-				return nil, fmt.Errorf("请修改配置文件")
-			} else {
-				log.Logger().Error("无法初始化配置文件")
-				panic(err)
-			}
-		}
+		log.Logger().Sugar().Infof(i18n.T("created %s; see 'cvtcli config list' for the available settings"), path)
+	}
+}
 
-		vc.SetConfigFile(DefaultConfigPath())
-		err = vc.ReadInConfig()
-		if err != nil {
-			return nil, err
-		}
-		log.Logger().Info("已加载配置文件：" + vc.ConfigFileUsed())
-		return vc, nil
+// firstRunLogin offers to store a token on the first run when none is
+// configured and cvtcli runs on a terminal; otherwise, or when the user
+// skips, cvtcli continues anonymously, which is enough for public files.
+func firstRunLogin(vc *viper.Viper) {
+	if _, source := resolveToken(vc); source != tokenNone {
+		return
+	}
+	if !isInteractive() {
+		log.Logger().Info(i18n.T("no api-key configured, continuing anonymously (set CVTCLI_API_KEY or run 'cvtcli auth login' for restricted models)"))
+		return
 	}
+
+	token, err := readSecret(i18n.T("Civitai API token, needed for restricted models (Enter to continue anonymously): "))
+	if err != nil || token == "" {
+		log.Logger().Info(i18n.T("continuing anonymously; run 'cvtcli auth login' later to add a token"))
+		return
+	}
+	me, err := checkToken(token)
+	if err != nil {
+		log.Logger().Sugar().Warnf(i18n.T("%v; continuing anonymously"), err)
+		return
+	}
+	profile := activeProfile(vc)
+	store := openSecretStore()
+	if err := store.Set(profile, token); err != nil {
+		// Still use it for this run.
+		log.Logger().Sugar().Warnf(i18n.T("cannot store the token in %s: %v"), store.Name(), err)
+		rememberToken(token, "first-run prompt")
+		return
+	}
+	rememberToken(token, store.Name())
+	log.Logger().Sugar().Infof(i18n.T("logged in as %s (token stored in %s)"), me.Username, store.Name())
+}
+
+// isInteractive reports whether cvtcli may prompt: stdin and stderr
+// must both be terminals.
+func isInteractive() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stderr.Fd()))
 }
 
+// DefaultConfigPath is config.yaml inside util.ConfigDir.
 func DefaultConfigPath() string {
-	u, err := user.Current()
-	if err != nil {
-		return ""
+	return filepath.Join(util.ConfigDir(), "config.yaml")
+}
+
+// configFilePath is the file the current invocation reads and writes:
+// --config when given, otherwise DefaultConfigPath.
+func configFilePath() string {
+	if ConfigFilePath != "" {
+		return ConfigFilePath
 	}
-	return u.HomeDir + "/.cvtcli/config.yaml"
+	return DefaultConfigPath()
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&ConfigFilePath, "config", "", "config file (default: see 'cvtcli config path')")
 }
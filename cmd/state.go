@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"civitai-model-downloader/util"
+)
+
+// stateDirName is the hidden directory inside a download dir that holds
+// the bookkeeping of incremental runs (creator catalogs, mirrors).
+const stateDirName = ".cvtcli"
+
+// seenState remembers which model versions a job already fetched so a
+// rerun only downloads what is new.
+type seenState struct {
+	path     string
+	Versions map[int]time.Time `json:"versions"`
+}
+
+func statePath(outputDir, name string) string {
+	return filepath.Join(outputDir, stateDirName, name+".json")
+}
+
+// loadSeenState reads the state file at path. A missing file yields an
+// empty state.
+func loadSeenState(path string) (*seenState, error) {
+	s := &seenState{path: path, Versions: map[int]time.Time{}}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if s.Versions == nil {
+		s.Versions = map[int]time.Time{}
+	}
+	return s, nil
+}
+
+func (s *seenState) Has(versionId int) bool {
+	_, ok := s.Versions[versionId]
+	return ok
+}
+
+func (s *seenState) Mark(versionId int) {
+	s.Versions[versionId] = time.Now().UTC()
+}
+
+func (s *seenState) Save() error {
+	return saveJSON(s.path, s)
+}
+
+// saveJSON writes v through a temp file so an interrupted run never
+// leaves a truncated state behind.
+func saveJSON(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := util.CreateFile(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
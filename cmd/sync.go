@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"slices"
+	"strings"
+
+	"civitai-model-downloader/api"
+	"civitai-model-downloader/dto"
+	"civitai-model-downloader/i18n"
+	"civitai-model-downloader/log"
+)
+
+// syncOptions controls which models and files syncModels downloads.
+type syncOptions struct {
+	BaseModels []string
+	// Versions is the number of newest versions per model, 0 for all.
+	Versions int
+	Files    fileFilter
+	Format   string
+	// MaxItems is the number of accepted models to sync, 0 for all.
+	MaxItems int
+	// Accept, when set, drops models before any of their versions are
+	// considered.
+	Accept func(dto.ModelItem) bool
+}
+
+type syncStats struct {
+	Fetched, Skipped, Failed int
+}
+
+// err reports failed versions as an error, so the command exits
+// non-zero.
+func (s syncStats) err() error {
+	if s.Failed == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d versions failed", s.Failed, s.Fetched+s.Failed)
+}
+
+// syncModels lists every model matching req and downloads the selected
+// versions into outputDir. Versions recorded in state are skipped and
+// every successful download is persisted right away, so an interrupted
+// run loses nothing.
+func syncModels(ctx context.Context, req *dto.ModelRequest, outputDir string, state *seenState, opts syncOptions) (syncStats, error) {
+	var stats syncStats
+	models := acceptedModels(api.IterateModels(ctx, req), opts.Accept, opts.MaxItems)
+	for model, err := range models {
+		if err != nil {
+			return stats, err
+		}
+		for _, v := range selectVersions(model.ModelVersions, opts.BaseModels, opts.Versions) {
+			if state.Has(v.ID) {
+				stats.Skipped++
+				continue
+			}
+			files := selectFiles(v.Files, opts.Files, opts.Format)
+			if len(files) == 0 {
+				log.Logger().Sugar().Warnf(i18n.T("%s / %s: no matching files"), model.Name, v.Name)
+				continue
+			}
+			failed, err := downloadFiles(ctx, files, v.DownloadURL, compactNameInfo(model, v), outputDir)
+			if err != nil {
+				return stats, err
+			}
+			if failed > 0 {
+				stats.Failed++
+				continue
+			}
+			state.Mark(v.ID)
+			if err := state.Save(); err != nil {
+				return stats, fmt.Errorf("save state: %w", err)
+			}
+			stats.Fetched++
+		}
+	}
+	return stats, nil
+}
+
+// acceptedModels yields the models of seq that accept lets through, at
+// most limit of them (all for 0), and passes errors on. The cap counts
+// accepted models, so filtered ones don't use it up.
+func acceptedModels(seq iter.Seq2[dto.ModelItem, error], accept func(dto.ModelItem) bool, limit int) iter.Seq2[dto.ModelItem, error] {
+	return func(yield func(dto.ModelItem, error) bool) {
+		n := 0
+		for model, err := range seq {
+			if err == nil && accept != nil && !accept(model) {
+				continue
+			}
+			if !yield(model, err) || err != nil {
+				return
+			}
+			if n++; limit > 0 && n >= limit {
+				return
+			}
+		}
+	}
+}
+
+// selectVersions returns the versions worth downloading: the limit
+// newest ones (all of them for 0), after dropping those for other base
+// models. The API lists versions newest first.
+func selectVersions(versions []dto.ModelVersionCompact, baseModels []string, limit int) []dto.ModelVersionCompact {
+	var out []dto.ModelVersionCompact
+	for _, v := range versions {
+		if len(baseModels) > 0 && !slices.ContainsFunc(baseModels, func(b string) bool {
+			return strings.EqualFold(b, v.BaseModel)
+		}) {
+			continue
+		}
+		out = append(out, v)
+		if limit > 0 && len(out) >= limit {
+			break
+		}
+	}
+	return out
+}
+
+// pickFile chooses the file to download from a version. With no format
+// the primary file wins; otherwise the first file whose metadata format
+// matches.
+func pickFile(files []dto.File, format string) (dto.File, bool) {
+	for _, f := range files {
+		if format == "" && f.Primary {
+			return f, true
+		}
+		if format != "" && f.Metadata != nil && strings.EqualFold(f.Metadata.Format, format) {
+			return f, true
+		}
+	}
+	if format == "" && len(files) > 0 {
+		return files[0], true
+	}
+	return dto.File{}, false
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+
+	"civitai-model-downloader/dto"
+)
+
+func TestSelectVersions(t *testing.T) {
+	versions := []dto.ModelVersionCompact{
+		{ID: 3, BaseModel: "Flux.1 D"},
+		{ID: 2, BaseModel: "SDXL 1.0"},
+		{ID: 1, BaseModel: "SDXL 1.0"},
+	}
+	if got := selectVersions(versions, nil, 1); len(got) != 1 || got[0].ID != 3 {
+		t.Fatalf("latest: %v", got)
+	}
+	if got := selectVersions(versions, []string{"sdxl 1.0"}, 1); len(got) != 1 || got[0].ID != 2 {
+		t.Fatalf("latest sdxl: %v", got)
+	}
+	if got := selectVersions(versions, []string{"SDXL 1.0"}, 0); len(got) != 2 {
+		t.Fatalf("all sdxl: %v", got)
+	}
+}
+
+func TestAcceptedModelsCapsAccepted(t *testing.T) {
+	listing := func(yield func(dto.ModelItem, error) bool) {
+		for id := 1; id <= 10; id++ {
+			if !yield(dto.ModelItem{ID: id}, nil) {
+				return
+			}
+		}
+		yield(dto.ModelItem{}, errors.New("no more pages"))
+	}
+	even := func(m dto.ModelItem) bool { return m.ID%2 == 0 }
+
+	var got []int
+	for m, err := range acceptedModels(listing, even, 3) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, m.ID)
+	}
+	if len(got) != 3 || got[0] != 2 || got[2] != 6 {
+		t.Fatalf("capped: %v", got)
+	}
+
+	got = nil
+	var err error
+	for m, e := range acceptedModels(listing, even, 0) {
+		if e != nil {
+			err = e
+			break
+		}
+		got = append(got, m.ID)
+	}
+	if len(got) != 5 || err == nil {
+		t.Fatalf("uncapped: %v, %v", got, err)
+	}
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"civitai-model-downloader/api"
+	"civitai-model-downloader/util"
+
+	"github.com/spf13/viper"
+)
+
+var (
+	flagTimeout      time.Duration
+	flagStallTimeout time.Duration
+)
+
+// jobTimedOut is set when the job ran past --timeout. The command then
+// fails with exitTimeout, whatever it returned.
+var jobTimedOut atomic.Bool
+
+// jobContext is the context a command does its work under: cancelled by
+// SIGINT/SIGTERM and, with --timeout, once the job has run that long.
+func jobContext() (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	if flagTimeout <= 0 {
+		return ctx, stop
+	}
+	ctx, cancel := context.WithTimeout(ctx, flagTimeout)
+	context.AfterFunc(ctx, func() {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			jobTimedOut.Store(true)
+		}
+	})
+	return ctx, func() { cancel(); stop() }
+}
+
+// configureTimeouts applies the API and stall timeouts of the active
+// profile.
+func configureTimeouts(vc *viper.Viper) error {
+	d, err := time.ParseDuration(vc.GetString("api-timeout"))
+	if err != nil || d <= 0 {
+		return fmt.Errorf("invalid api-timeout %q", vc.GetString("api-timeout"))
+	}
+	api.RequestTimeout = d
+	if flagStallTimeout < 0 {
+		return fmt.Errorf("invalid --stall-timeout %s", flagStallTimeout)
+	}
+	util.SetStallTimeout(flagStallTimeout)
+	return nil
+}
+
+func init() {
+	rootCmd.PersistentFlags().DurationVar(&flagTimeout, "timeout", 0, "give up a job that runs longer than this (e.g. 2h); 0 is no limit")
+	rootCmd.PersistentFlags().DurationVar(&flagStallTimeout, "stall-timeout", 60*time.Second, "abort and retry a transfer that receives no data for this long; 0 disables")
+	bindFlagToConfig(rootCmd.PersistentFlags(), "stall-timeout", "stall-timeout")
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"civitai-model-downloader/dto"
+	"civitai-model-downloader/i18n"
+	"civitai-model-downloader/log"
+	"civitai-model-downloader/util"
+)
+
+// verifying reports whether verifyDownload will hash a file with
+// these hashes, i.e. whether the API published a SHA256 for it.
+func verifying(hashes *dto.FileHashes) bool {
+	return hashes != nil && hashes.SHA256 != ""
+}
+
+// verifyDownload checks a finished download before it gets its final
+// name: its length against size when that is known, and its sums
+// against the published hashes. A file that fails is never renamed. A
+// short file is kept so the next run resumes it; on a hash mismatch the
+// verify setting decides: "strict" deletes the file, anything else
+// keeps it for inspection. Files without a published SHA256 only get
+// the size check.
+// The sums come from stream when it hashed the download as it was
+// written, otherwise from reading the file. A streamed mismatch is
+// confirmed from disk before the file is rejected.
+func verifyDownload(path string, size int64, hashes *dto.FileHashes, stream *util.StreamHash) error {
+	if size > 0 {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("verify: %w", err)
+		}
+		if fi.Size() != size {
+			return fmt.Errorf("%s: %d bytes, expected %d", path, fi.Size(), size)
+		}
+	}
+	if !verifying(hashes) {
+		return nil
+	}
+	var sums util.Sums
+	var err error
+	if stream != nil {
+		sums, err = stream.Finish()
+	} else {
+		sums, err = util.FileSums(path)
+	}
+	if err != nil {
+		return fmt.Errorf("verify: %w", err)
+	}
+	algo, got, want := mismatch(sums, hashes)
+	if algo != "" && stream != nil {
+		if sums, err = util.FileSums(path); err != nil {
+			return fmt.Errorf("verify: %w", err)
+		}
+		algo, got, want = mismatch(sums, hashes)
+	}
+	if algo == "" {
+		log.Logger().Sugar().Infof(i18n.T("verified %s (sha256 %s)"), path, sums.SHA256)
+		return nil
+	}
+	if appConfig.GetString("verify") != "strict" {
+		return fmt.Errorf("%s: %s mismatch, got %s want %s; file kept", path, algo, got, want)
+	}
+	os.Remove(path)
+	return fmt.Errorf("%s: %s mismatch, got %s want %s; file removed", path, algo, got, want)
+}
+
+// mismatch compares sums with the published hashes and returns the
+// first digest that differs, or "" when all that are known agree.
+func mismatch(sums util.Sums, hashes *dto.FileHashes) (algo, got, want string) {
+	for _, c := range []struct{ algo, got, want string }{
+		{"sha256", sums.SHA256, hashes.SHA256},
+		{"crc32", sums.CRC32, hashes.CRC32},
+		{"blake3", sums.BLAKE3, hashes.BLAKE3},
+	} {
+		if c.got != "" && c.want != "" && !strings.EqualFold(c.got, c.want) {
+			return c.algo, c.got, strings.ToLower(c.want)
+		}
+	}
+	return "", "", ""
+}
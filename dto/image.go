@@ -0,0 +1,87 @@
+package dto
+
+import (
+	"encoding/json"
+	"net/url"
+	"time"
+)
+
+// ── Request: GET /api/v1/images ─────────────────────
+
+type ImageRequest struct {
+	Limit          *int    `url:"limit,omitempty"`
+	Page           *int    `url:"page,omitempty"`
+	Cursor         *string `url:"cursor,omitempty"`
+	PostID         *int    `url:"postId,omitempty"`
+	ModelID        *int    `url:"modelId,omitempty"`
+	ModelVersionID *int    `url:"modelVersionId,omitempty"`
+	Username       *string `url:"username,omitempty"`
+	NSFW           *string `url:"nsfw,omitempty"`
+	Sort           *string `url:"sort,omitempty"`
+	Period         *string `url:"period,omitempty"`
+}
+
+func (r *ImageRequest) add(p url.Values) {
+	if r == nil {
+		return
+	}
+	intPtr(p, "limit", r.Limit)
+	intPtr(p, "page", r.Page)
+	strPtr(p, "cursor", r.Cursor)
+	intPtr(p, "postId", r.PostID)
+	intPtr(p, "modelId", r.ModelID)
+	intPtr(p, "modelVersionId", r.ModelVersionID)
+	strPtr(p, "username", r.Username)
+	strPtr(p, "nsfw", r.NSFW)
+	strPtr(p, "sort", r.Sort)
+	strPtr(p, "period", r.Period)
+}
+
+func (r *ImageRequest) QueryString() string {
+	p := url.Values{}
+	r.add(p)
+	s := p.Encode()
+	if s == "" {
+		return ""
+	}
+	return "?" + s
+}
+
+// ── Response: GET /api/v1/images ────────────────────
+
+type ImagesResponse struct {
+	Items    []ImageItem `json:"items"`
+	Metadata *Metadata   `json:"metadata,omitempty"`
+}
+
+// ImageItem is a showcase image with its generation parameters. Meta
+// holds the raw generation metadata (prompt, negativePrompt, sampler,
+// seed, steps, cfgScale, resources, ...) as uploaded, so it is kept
+// verbatim rather than mapped onto a fixed struct.
+type ImageItem struct {
+	ID        int              `json:"id"`
+	URL       string           `json:"url"`
+	Hash      string           `json:"hash"`
+	Width     int              `json:"width"`
+	Height    int              `json:"height"`
+	NSFW      bool             `json:"nsfw"`
+	NSFWLevel string           `json:"nsfwLevel"`
+	Type      string           `json:"type"`
+	CreatedAt *time.Time       `json:"createdAt"`
+	PostID    int              `json:"postId"`
+	Username  string           `json:"username"`
+	BaseModel string           `json:"baseModel"`
+	Stats     *ImageStats      `json:"stats,omitempty"`
+	Meta      *json.RawMessage `json:"meta"`
+}
+
+type ImageStats struct {
+	CryCount     int `json:"cryCount"`
+	LaughCount   int `json:"laughCount"`
+	LikeCount    int `json:"likeCount"`
+	HeartCount   int `json:"heartCount"`
+	CommentCount int `json:"commentCount"`
+}
+
+// NSFWLevels lists the image NSFW levels from least to most explicit.
+var NSFWLevels = []string{"None", "Soft", "Mature", "X"}
@@ -37,5 +37,27 @@ type ModelVersionFull struct {
 	DownloadURL       string           `json:"downloadUrl"`
 }
 
+// EarlyAccessUntil reports when the early-access period of the version
+// ends. EarlyAccessEndsAt wins; otherwise the end is derived from the
+// timeframe (in days) of EarlyAccessConfig, counted from PublishedAt.
+// ok is false when the version is not, or no longer, in early access.
+func (v *ModelVersionFull) EarlyAccessUntil(now time.Time) (until time.Time, ok bool) {
+	switch {
+	case v.EarlyAccessEndsAt != nil:
+		until = *v.EarlyAccessEndsAt
+	case v.EarlyAccessConfig != nil && !v.PublishedAt.IsZero():
+		var cfg struct {
+			Timeframe int `json:"timeframe"`
+		}
+		if err := json.Unmarshal(*v.EarlyAccessConfig, &cfg); err != nil || cfg.Timeframe <= 0 {
+			return time.Time{}, false
+		}
+		until = v.PublishedAt.AddDate(0, 0, cfg.Timeframe)
+	default:
+		return time.Time{}, false
+	}
+	return until, until.After(now)
+}
+
 // ModelVersionIdResponse is the old name, kept for backward compat.
 type ModelVersionIdResponse = ModelVersionFull
@@ -0,0 +1,12 @@
+package dto
+
+// ── Response: GET /api/v1/me ────────────────────────
+
+// MeResponse describes the account behind the API token.
+type MeResponse struct {
+	ID       int    `json:"id"`
+	Username string `json:"username"`
+	Tier     string `json:"tier"`
+	Status   string `json:"status"`
+	IsMember bool   `json:"isMember"`
+}
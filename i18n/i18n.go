@@ -0,0 +1,71 @@
+// Package i18n translates user-facing text. Messages are written in
+// English in the source and double as catalog keys, so an untranslated
+// message simply stays English. Format verbs are kept intact by the
+// translations; callers pass the result to Printf-style functions or
+// let T format it.
+package i18n
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Supported languages. English is the source language.
+const (
+	English = "en"
+	Chinese = "zh"
+)
+
+var catalogs = map[string]map[string]string{
+	Chinese: zh,
+}
+
+var current = English
+
+// SetLang selects the language for T. It accepts POSIX locale names
+// such as "zh_CN.UTF-8" as well as plain tags; unsupported languages
+// fall back to English.
+func SetLang(tag string) {
+	current = Normalize(tag)
+}
+
+// Lang returns the selected language.
+func Lang() string {
+	return current
+}
+
+// Normalize reduces a locale name to one of the supported languages.
+func Normalize(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "_-.@"); i >= 0 {
+		tag = tag[:i]
+	}
+	if _, ok := catalogs[tag]; ok {
+		return tag
+	}
+	return English
+}
+
+// FromEnv reads the language from LC_ALL, LC_MESSAGES or LANG, in the
+// order POSIX gives them precedence.
+func FromEnv() string {
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(env); v != "" && v != "C" && v != "POSIX" {
+			return Normalize(v)
+		}
+	}
+	return English
+}
+
+// T translates msg into the selected language. With args, the
+// translation is used as a format string.
+func T(msg string, args ...any) string {
+	if tr, ok := catalogs[current][msg]; ok {
+		msg = tr
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
@@ -0,0 +1,39 @@
+package i18n
+
+import (
+	"regexp"
+	"testing"
+)
+
+var verbRe = regexp.MustCompile(`%(\[\d+\])?[-+# 0-9.]*[a-zA-Z%]`)
+
+// Translations must consume the same arguments as the English message.
+func TestCatalogVerbs(t *testing.T) {
+	for lang, catalog := range catalogs {
+		for msg, tr := range catalog {
+			if a, b := len(verbRe.FindAllString(msg, -1)), len(verbRe.FindAllString(tr, -1)); a != b {
+				t.Errorf("%s: %q has %d verbs, translation %q has %d", lang, msg, a, tr, b)
+			}
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	cases := map[string]string{"zh_CN.UTF-8": Chinese, "zh-TW": Chinese, "en_US.UTF-8": English, "fr_FR": English, "": English}
+	for in, want := range cases {
+		if got := Normalize(in); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestT(t *testing.T) {
+	defer SetLang(English)
+	SetLang("zh_CN.UTF-8")
+	if got := T("download complete: %s", "a.safetensors"); got != "下载完成：a.safetensors" {
+		t.Errorf("got %q", got)
+	}
+	if got := T("untranslated %d", 1); got != "untranslated 1" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,167 @@
+package i18n
+
+// zh is the Simplified Chinese catalog.
+var zh = map[string]string{
+	// cobra usage template
+	"Usage:":                  "用法：",
+	"Aliases:":                "别名：",
+	"Examples:":               "示例：",
+	"Available Commands:":     "可用命令：",
+	"Additional Commands:":    "其他命令：",
+	"Flags:":                  "参数：",
+	"Global Flags:":           "全局参数：",
+	"Additional help topics:": "更多帮助主题：",
+	`Use "{{.CommandPath}} [command] --help" for more information about a command.`: `使用 "{{.CommandPath}} [command] --help" 查看命令的详细说明。`,
+
+	// cobra's completion command
+	"Generate the autocompletion script for the specified shell": "生成指定 shell 的自动补全脚本",
+	"Generate the autocompletion script for bash":                "生成 bash 自动补全脚本",
+	"Generate the autocompletion script for zsh":                 "生成 zsh 自动补全脚本",
+	"Generate the autocompletion script for fish":                "生成 fish 自动补全脚本",
+	"Generate the autocompletion script for powershell":          "生成 powershell 自动补全脚本",
+
+	// commands
+	"Download models, images and whole catalogs from Civitai":                 "从 Civitai 下载模型、图片和整个作者目录",
+	"Download a model, a version, its files or a creator's catalog":           "下载模型、版本、版本文件或作者的全部作品",
+	"Download showcase images and their generation parameters":                "下载示例图片及其生成参数",
+	"Keep local directories in sync with saved searches":                      "让本地目录与保存的搜索条件保持同步",
+	"Inspect and edit the cvtcli configuration":                               "查看和修改 cvtcli 配置",
+	"Write a config file listing every setting at its default, commented out": "写入列出全部设置及其默认值（均已注释）的配置文件",
+	"Print the effective value of a setting":                                  "显示某项设置的生效值",
+	"Change a setting in the config file (of a profile with --profile)":       "修改配置文件中的设置（配合 --profile 修改指定配置档）",
+	"List every setting with its effective value":                             "列出全部设置及其生效值",
+	"Print the path of the config file":                                       "显示配置文件路径",
+	"Manage the Civitai API token of a profile":                               "管理配置档的 Civitai API 令牌",
+	"Check a token against Civitai and store it in the keyring":               "向 Civitai 校验令牌并保存到密钥环",
+	"Remove the stored token of the profile":                                  "删除配置档已保存的令牌",
+	"Show where the token comes from and whether Civitai accepts it":          "显示令牌来源以及 Civitai 是否接受该令牌",
+
+	// flags
+	"config file (default: see 'cvtcli config path')":             "配置文件（默认见 'cvtcli config path'）",
+	"configuration profile to use":                                "使用的配置档",
+	"language of messages and help: en or zh (default from LANG)": "消息和帮助的语言：en 或 zh（默认取自 LANG）",
+	"direct download URL":                                         "直接下载地址",
+	"model ID, or a model name from the local library":            "模型 ID，或本地库中的模型名",
+	"model hash": "模型哈希",
+	"model version ID, or a version name from the local library":              "模型版本 ID，或本地库中的版本名",
+	"Measure download throughput for combinations of threads and chunk sizes": "测量不同线程数与分块大小组合下的下载速度",
+	"URL to benchmark": "要测速的 URL",
+	"benchmark the primary file of this model version":                              "对该模型版本的主文件测速",
+	"benchmark against a local test server":                                         "使用本地测试服务器测速",
+	"per-connection speed of the local server, e.g. 20M; empty is unlimited":        "本地服务器每个连接的速度，如 20M；留空表示不限速",
+	"bytes to download per run":                                                     "每轮下载的字节数",
+	"thread counts to try":                                                          "要尝试的线程数",
+	"chunk sizes to try":                                                            "要尝试的分块大小",
+	"store the recommended threads and chunk-size in the config file":               "将推荐的 threads 和 chunk-size 写入配置文件",
+	"output directory":                                                              "输出目录",
+	"number of concurrent download threads, or auto to tune them to the connection": "并发下载线程数，或 auto 按网络状况自动调整",
+	"chunk size for dynamic worker pool (e.g. 16M, 1G, 16777216; empty=auto)":       "动态工作池的分块大小（如 16M、1G、16777216；留空为自动）",
+	"download every model published by this username":                               "下载该用户发布的全部模型",
+	"download every version instead of only the latest":                             "下载全部版本而不只是最新版本",
+	"only models of these types (e.g. LORA,Checkpoint)":                             "仅限这些类型的模型（如 LORA,Checkpoint）",
+	"only versions for these base models (e.g. \"SDXL 1.0\")":                       "仅限这些基础模型的版本（如 \"SDXL 1.0\"）",
+	"preferred file format (e.g. SafeTensor, PickleTensor)":                         "优先的文件格式（如 SafeTensor、PickleTensor）",
+	"files of each version to fetch: primary, all, or type=VAE,Config":              "每个版本要下载的文件：primary、all 或 type=VAE,Config",
+	"versions of a model to fetch: latest, all, or the N newest":                    "要下载的模型版本：latest、all 或最新的 N 个",
+	"(deprecated, unused) kept for backward compatibility":                          "（已弃用，不再使用）仅为向后兼容保留",
+	"highest NSFW level to include: None, Soft, Mature or X":                        "包含的最高 NSFW 等级：None、Soft、Mature 或 X",
+	"maximum number of images (0 = all)":                                            "最多下载的图片数（0 表示全部）",
+	"rerun every interval (e.g. 6h); 0 runs once":                                   "每隔指定时间重新运行（如 6h）；0 表示只运行一次",
+	"overwrite an existing config file":                                             "覆盖已存在的配置文件",
+	"print api-key in full instead of masked":                                       "完整显示 api-key，不做遮掩",
+	"token to store (default: prompt, or read from stdin)":                          "要保存的令牌（默认提示输入，或从标准输入读取）",
+
+	// config schema
+	"profile used when --profile is not given":                                                                                              "未指定 --profile 时使用的配置档",
+	"language of messages and help: en or zh; empty follows LANG":                                                                           "消息和帮助的语言：en 或 zh；留空则跟随 LANG",
+	"Civitai API token in plaintext; prefer 'cvtcli auth login' (also CVTCLI_API_KEY or CIVITAI_TOKEN)":                                     "明文保存的 Civitai API 令牌；建议使用 'cvtcli auth login'（也可用 CVTCLI_API_KEY 或 CIVITAI_TOKEN）",
+	"directory downloads are written to":                                                                                                    "下载文件的保存目录",
+	"concurrent download threads per file, or auto to tune them to the connection":                                                          "每个文件的并发下载线程数，或 auto 按网络状况自动调整",
+	"chunk size, e.g. 16M or 1G; empty picks one automatically":                                                                             "分块大小，如 16M 或 1G；留空则自动选择",
+	"directory layout under download-dir: flat, or by-type for <type>/<base model>/":                                                        "download-dir 下的目录结构：flat，或 by-type 表示 <类型>/<基础模型>/",
+	"file name template; placeholders: {filename} {name} {ext} {model} {version} {modelId} {versionId} {type} {baseModel}":                  "文件名模板；占位符：{filename} {name} {ext} {model} {version} {modelId} {versionId} {type} {baseModel}",
+	"when a download's SHA256 differs from the API's (it never gets its final name): off or warn keep the .part file, strict deletes it":    "SHA256 与 API 不一致时（下载始终不会获得最终文件名）：off 或 warn 保留 .part 文件，strict 删除",
+	"proxy URL for all requests: http, https, socks5 or socks5h, optionally user:pass@; empty uses HTTP_PROXY / HTTPS_PROXY, none disables": "所有请求使用的代理地址：http、https、socks5 或 socks5h，可带 user:pass@；留空则使用 HTTP_PROXY / HTTPS_PROXY，none 表示不使用代理",
+	"PEM file of extra CA certificates to trust, e.g. a corporate gateway's":                                                                "额外信任的 CA 证书（PEM 文件），如公司网关的证书",
+	"PEM client certificate for gateways that require mutual TLS":                                                                           "双向 TLS 网关所需的客户端证书（PEM）",
+	"PEM private key of client-cert":                                                                                                        "client-cert 对应的私钥（PEM）",
+	"skip TLS certificate verification (unsafe; last resort)":                                                                               "跳过 TLS 证书校验（不安全，仅作最后手段）",
+	"skip TLS certificate verification":                                                                                                     "跳过 TLS 证书校验",
+	"time limit for opening a connection":                                                                                                   "建立连接的超时时间",
+	"time limit for the TLS handshake of a connection":                                                                                      "连接 TLS 握手的超时时间",
+	"time limit for a server to start answering; empty follows stall-timeout":                                                               "等待服务器开始响应的超时时间；留空则与 stall-timeout 相同",
+	"interval of TCP keep-alive probes on open connections; 0 disables them":                                                                "已建立连接的 TCP keep-alive 探测间隔；0 表示关闭",
+	"use HTTP/2 with servers that offer it; chunk requests then share one connection, so more threads add little":                           "服务器支持时使用 HTTP/2；分块请求随后共用一个连接，因此增加线程收效甚微",
+	"socket send and receive buffer size, e.g. 4M; empty lets the OS tune them":                                                             "套接字收发缓冲区大小，如 4M；留空由操作系统自动调整",
+	"time limit for each API request":                                                                                                       "每个 API 请求的超时时间",
+	"abort and retry a transfer that receives no data for this long; 0 disables":                                                            "传输在这段时间内未收到数据时中止并重试；0 表示不检测",
+	"give up a job that runs longer than this (e.g. 2h); 0 is no limit":                                                                     "任务运行超过该时长（如 2h）时放弃；0 表示不限制",
+	"bandwidth cap for downloads, e.g. 20M; empty is unlimited":                                                                             "下载带宽上限，如 20M；留空表示不限速",
+	"time windows with their own cap, e.g. \"22:00-07:00=0, 12:00-13:00=50M\"; rate-limit applies outside them":                             "按时段单独限速，如 \"22:00-07:00=0, 12:00-13:00=50M\"；时段之外使用 rate-limit",
+	"total bandwidth cap, e.g. 20M (per second); empty is unlimited":                                                                        "总带宽上限（每秒），如 20M；留空表示不限速",
+	"when the target file exists: skip, overwrite, rename (add a number), or verify (download again on sha256 mismatch)":                    "目标文件已存在时：skip 跳过、overwrite 覆盖、rename 编号另存，或 verify（sha256 不符时重新下载）",
+	"when the target file exists: skip, overwrite, rename or verify (default from config, else skip)":                                       "目标文件已存在时：skip、overwrite、rename 或 verify（默认取配置，否则为 skip）",
+	"log level: debug, info, warn or error":                                                                                                 "日志级别：debug、info、warn 或 error",
+	"log format: console, json or logfmt":                                                                                                   "日志格式：console、json 或 logfmt",
+	"also write logs to this file, rotated at 50 MB":                                                                                        "同时将日志写入该文件，达到 50 MB 时轮转",
+	"only log errors":    "只输出错误日志",
+	"log debug messages": "输出调试日志",
+
+	// log and command output
+	"logger initialized":                            "日志初始化成功",
+	"loaded config file %s":                         "已加载配置文件：%s",
+	"cannot read %s: %v":                            "无法读取 %s：%v",
+	"cannot create %s, using built-in defaults: %v": "无法创建 %s，使用内置默认值：%v",
+	"created %s; see 'cvtcli config list' for the available settings":                                                     "配置文件已初始化：%s；可用设置见 'cvtcli config list'",
+	"no api-key configured, continuing anonymously (set CVTCLI_API_KEY or run 'cvtcli auth login' for restricted models)": "未配置 api-key，以匿名方式继续（受限模型请设置 CVTCLI_API_KEY 或运行 'cvtcli auth login'）",
+	"Civitai API token, needed for restricted models (Enter to continue anonymously): ":                                   "Civitai API 令牌，下载受限模型时需要（按回车以匿名方式继续）：",
+	"continuing anonymously; run 'cvtcli auth login' later to add a token":                                                "以匿名方式继续；之后可运行 'cvtcli auth login' 添加令牌",
+	"%v; continuing anonymously":           "%v；以匿名方式继续",
+	"cannot store the token in %s: %v":     "无法将令牌保存到 %s：%v",
+	"logged in as %s (token stored in %s)": "已登录为 %s（令牌保存在 %s）",
+	"cannot read token from %s: %v":        "无法从 %s 读取令牌：%v",
+	"api-key is stored in plaintext in %s; run 'cvtcli auth login' to move it to the keyring": "api-key 以明文保存在 %s 中；请运行 'cvtcli auth login' 将其移入密钥环",
+	"token stored, but the plaintext api-key could not be removed from the config: %v":        "令牌已保存，但无法从配置文件中删除明文 api-key：%v",
+	"logged in as %s (profile %s, stored in %s)\n":                                            "已登录为 %s（配置档 %s，保存在 %s）\n",
+	"profile %s has no stored token\n":                                                        "配置档 %s 没有已保存的令牌\n",
+	"removed the token of profile %s from %s\n":                                               "已从 %[2]s 删除配置档 %[1]s 的令牌\n",
+	"profile: %s\ntoken:   %s\n":                                                              "配置档：%s\n令牌：  %s\n",
+	"account: %s (%s)\n":                                                                      "账号：%s（%s）\n",
+	"not logged in; run 'cvtcli auth login'":                                                  "未登录；请运行 'cvtcli auth login'",
+	"Civitai rejected the token":                                                              "Civitai 拒绝了该令牌",
+	"downloading %s -> %s":                                                                    "正在下载 %s -> %s",
+	"download complete: %s":                                                                   "下载完成：%s",
+	"TLS certificate verification is disabled (--insecure)":                                   "已关闭 TLS 证书校验（--insecure）",
+	"%s already exists, skipping":                                                             "%s 已存在，跳过",
+	"%s exists but its sha256 does not match, downloading again":                              "%s 已存在但 sha256 不符，重新下载",
+	"interrupted, download state saved":                                                       "已中断，下载进度已保存",
+	"cannot reserve disk space for %s: %v":                                                    "无法为 %s 预留磁盘空间：%v",
+	"%s / %s: no matching files":                                                              "%s / %s：没有符合条件的文件",
+	"%s: no download URL":                                                                     "%s：没有下载地址",
+	"creator %s: %d downloaded, %d already present, %d failed":                                "作者 %s：已下载 %d 个，已存在 %d 个，失败 %d 个",
+	"mirror %s: syncing into %s":                                                              "镜像 %s：正在同步到 %s",
+	"mirror %s: %d downloaded, %d already present, %d failed":                                 "镜像 %s：已下载 %d 个，已存在 %d 个，失败 %d 个",
+	"adaptive concurrency settled on %d connections at %s/s":                                  "自适应并发稳定在 %d 个连接，速度 %s/s",
+	"benchmarking %s: %d runs of %s each":                                                     "正在测速 %s：共 %d 轮，每轮 %s",
+	"%d threads, chunk %s: %v":                                                                "%d 线程，分块 %s：%v",
+	"recommended: threads = %d, chunk-size = %s":                                              "推荐设置：threads = %d，chunk-size = %s",
+	"saved to %s":                   "已保存到 %s",
+	"mirror run timed out after %s": "镜像运行超时（%s）",
+	"timed out after %s":            "运行超时（%s）",
+	"mirror %s: %v":                 "镜像 %s：%v",
+	"next mirror run at %s":         "下次镜像运行时间：%s",
+	"image %d: %v":                  "图片 %d：%v",
+	"saved %d images to %s (%d filtered by nsfw level)": "已保存 %d 张图片到 %s（%d 张因 NSFW 等级被过滤）",
+	"verified %s (sha256 %s)":                           "校验通过 %s（sha256 %s）",
+
+	// download diagnostics
+	"this resource is not available in your region (HTTP %d)":                                                       "该资源在你所在的地区不可用（HTTP %d）",
+	"version %d is %s and can no longer be downloaded":                                                              "版本 %d 状态为 %s，已无法下载",
+	"version %d is private":                                                                                         "版本 %d 为私有",
+	"not found: the model or version was deleted or never existed (HTTP %d)":                                        "未找到：该模型或版本已删除或不存在（HTTP %d）",
+	"version %d is in early access until %s; it can be downloaded by supporters now or by everyone after that date": "版本 %d 处于抢先体验期，截止 %s；目前仅支持者可下载，之后所有人均可下载",
+	"this model requires a signed-in account: run 'cvtcli auth login' or set CVTCLI_API_KEY":                        "该模型需要登录账号：请运行 'cvtcli auth login' 或设置 CVTCLI_API_KEY",
+	"the configured api-key was rejected by Civitai; create a new one at https://civitai.com/user/account":          "Civitai 拒绝了配置的 api-key；请在 https://civitai.com/user/account 创建新的令牌",
+	"account %s is not allowed to download this file (HTTP %d)":                                                     "账号 %s 无权下载该文件（HTTP %d）",
+	"access denied (HTTP %d)":                                                                                       "拒绝访问（HTTP %d）",
+}
@@ -0,0 +1,98 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+var logfmtPool = buffer.NewPool()
+
+// logfmtEncoder writes entries as key=value pairs, one line per entry:
+//
+//	time=2024-05-01T10:00:00.000Z level=info msg="download complete" path=/x
+//
+// Context fields follow the fixed keys in sorted order. Values that are
+// not plain scalars are written as JSON.
+type logfmtEncoder struct {
+	*zapcore.MapObjectEncoder
+}
+
+func newLogfmtEncoder() zapcore.Encoder {
+	return &logfmtEncoder{zapcore.NewMapObjectEncoder()}
+}
+
+func (e *logfmtEncoder) Clone() zapcore.Encoder {
+	c := zapcore.NewMapObjectEncoder()
+	maps.Copy(c.Fields, e.Fields)
+	return &logfmtEncoder{c}
+}
+
+func (e *logfmtEncoder) EncodeEntry(entry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	enc := e.Clone().(*logfmtEncoder)
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+
+	buf := logfmtPool.Get()
+	writePair(buf, "time", entry.Time.Format("2006-01-02T15:04:05.000Z07:00"))
+	writePair(buf, "level", entry.Level.String())
+	if entry.LoggerName != "" {
+		writePair(buf, "logger", entry.LoggerName)
+	}
+	if entry.Caller.Defined {
+		writePair(buf, "caller", entry.Caller.TrimmedPath())
+	}
+	writePair(buf, "msg", entry.Message)
+	for _, k := range slices.Sorted(maps.Keys(enc.Fields)) {
+		writePair(buf, k, formatValue(enc.Fields[k]))
+	}
+	if entry.Stack != "" {
+		writePair(buf, "stacktrace", entry.Stack)
+	}
+	buf.AppendString(zapcore.DefaultLineEnding)
+	return buf, nil
+}
+
+func writePair(buf *buffer.Buffer, key, value string) {
+	if buf.Len() > 0 {
+		buf.AppendByte(' ')
+	}
+	buf.AppendString(key)
+	buf.AppendByte('=')
+	if value == "" || strings.ContainsAny(value, " =\"\t\r\n") {
+		buf.AppendString(strconv.Quote(value))
+		return
+	}
+	buf.AppendString(value)
+}
+
+func formatValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case error:
+		return v.Error()
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case time.Duration:
+		return v.String()
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr,
+		float32, float64, complex64, complex128:
+		return fmt.Sprint(v)
+	case fmt.Stringer:
+		return v.String()
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
@@ -0,0 +1,32 @@
+package log
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestLogfmtEncoder(t *testing.T) {
+	enc := newLogfmtEncoder()
+	enc.AddString("component", "api")
+	entry := zapcore.Entry{
+		Level:   zapcore.WarnLevel,
+		Time:    time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		Message: "request failed",
+	}
+	buf, err := enc.EncodeEntry(entry, []zapcore.Field{
+		zap.Int("status", 429),
+		zap.Error(errors.New("too many requests")),
+		zap.String("empty", ""),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `time=2024-05-01T10:00:00.000Z level=warn msg="request failed" component=api empty="" error="too many requests" status=429` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
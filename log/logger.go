@@ -29,7 +29,6 @@ func Logger() *zap.Logger {
 	if GlobalLogger == nil {
 		logger, _ := NewConsoleLogger()
 		GlobalLogger = logger
-		logger.Info("日志初始化成功")
 	}
 	return GlobalLogger
 }
@@ -39,8 +38,15 @@ func NewCliLogger() *CliLogger {
 	return &CliLogger{logger}
 }
 
+// NewConsoleLogger returns the default logger used until Configure is
+// called: colored console output at info level. Colors are dropped
+// automatically when stdout is not a terminal or NO_COLOR is set.
 func NewConsoleLogger() (*zap.Logger, error) {
-	encoderConfig := zapcore.EncoderConfig{
+	return New(Options{})
+}
+
+func consoleEncoderConfig() zapcore.EncoderConfig {
+	return zapcore.EncoderConfig{
 		TimeKey:          "T",
 		LevelKey:         "L",
 		NameKey:          "N",
@@ -51,17 +57,9 @@ func NewConsoleLogger() (*zap.Logger, error) {
 		EncodeLevel:      customLevelColorEncoder,
 		EncodeTime:       customTimeEncoder,
 		EncodeDuration:   zapcore.StringDurationEncoder,
-		EncodeCaller:     zapcore.FullCallerEncoder,
+		EncodeCaller:     zapcore.ShortCallerEncoder,
 		ConsoleSeparator: " ",
 	}
-
-	core := zapcore.NewCore(
-		&CustomEncoder{zapcore.NewConsoleEncoder(encoderConfig)},
-		zapcore.AddSync(color.Output),
-		zapcore.DebugLevel,
-	)
-	return zap.New(core, zap.AddCaller()), nil
-
 }
 
 type CustomEncoder struct {
@@ -90,7 +88,6 @@ func (c *CustomEncoder) EncodeEntry(entry zapcore.Entry, fields []zap.Field) (*b
 }
 func customLevelColorEncoder(level zapcore.Level, enc zapcore.PrimitiveArrayEncoder) {
 	var colorize func(a ...interface{}) string
-	color.NoColor = false
 
 	switch level {
 	case zapcore.DebugLevel:
@@ -0,0 +1,148 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/fatih/color"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Log formats accepted by Options.Format.
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+	FormatLogfmt  = "logfmt"
+)
+
+// Options configures the global logger.
+type Options struct {
+	// Level is debug, info, warn or error; empty means info.
+	Level string
+	// Format is console (default), json or logfmt.
+	Format string
+	// File, when set, receives a copy of every entry without colors.
+	// It is rotated once it reaches MaxSizeMB, keeping MaxBackups old
+	// files.
+	File       string
+	MaxSizeMB  int
+	MaxBackups int
+}
+
+// Configure replaces the global logger according to opts.
+func Configure(opts Options) error {
+	logger, err := New(opts)
+	if err != nil {
+		return err
+	}
+	if GlobalLogger != nil {
+		GlobalLogger.Sync()
+	}
+	GlobalLogger = logger
+	if opts.File == "" {
+		closeRotatingFile()
+	}
+	return nil
+}
+
+// New builds a logger writing to stdout and, optionally, to a rotated
+// log file. The caller location is only added at debug level, where it
+// helps; at other levels it is noise in CI logs.
+func New(opts Options) (*zap.Logger, error) {
+	level, err := zapcore.ParseLevel(strings.ToLower(opts.Level))
+	if opts.Level == "" {
+		level, err = zapcore.InfoLevel, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("log level %q: %w", opts.Level, err)
+	}
+
+	stdout, err := newEncoder(opts.Format, !color.NoColor)
+	if err != nil {
+		return nil, err
+	}
+	cores := []zapcore.Core{zapcore.NewCore(stdout, zapcore.AddSync(color.Output), level)}
+
+	if opts.File != "" {
+		plain, _ := newEncoder(opts.Format, false)
+		cores = append(cores, zapcore.NewCore(plain, zapcore.AddSync(newRotatingFile(opts)), level))
+	}
+
+	var zopts []zap.Option
+	if level == zapcore.DebugLevel {
+		zopts = append(zopts, zap.AddCaller())
+	}
+	return zap.New(zapcore.NewTee(cores...), zopts...), nil
+}
+
+func newEncoder(format string, colored bool) (zapcore.Encoder, error) {
+	switch strings.ToLower(format) {
+	case "", FormatConsole:
+		cfg := consoleEncoderConfig()
+		if !colored {
+			cfg.EncodeLevel = zapcore.CapitalLevelEncoder
+			cfg.EncodeTime = zapcore.TimeEncoderOfLayout("2006-01-02 15:04:05.000")
+			return zapcore.NewConsoleEncoder(cfg), nil
+		}
+		return &CustomEncoder{zapcore.NewConsoleEncoder(cfg)}, nil
+	case FormatJSON:
+		cfg := zap.NewProductionEncoderConfig()
+		cfg.EncodeTime = zapcore.ISO8601TimeEncoder
+		return zapcore.NewJSONEncoder(cfg), nil
+	case FormatLogfmt:
+		return newLogfmtEncoder(), nil
+	}
+	return nil, fmt.Errorf("unknown log format %q (want console, json or logfmt)", format)
+}
+
+// rotator is the log file. Configure runs more than once per process,
+// so it is kept open while the file settings stay the same and closed
+// when they change.
+var (
+	rotatorMu sync.Mutex
+	rotator   *lumberjack.Logger
+)
+
+func newRotatingFile(opts Options) io.Writer {
+	size := opts.MaxSizeMB
+	if size <= 0 {
+		size = 50
+	}
+	backups := opts.MaxBackups
+	if backups <= 0 {
+		backups = 3
+	}
+	rotatorMu.Lock()
+	defer rotatorMu.Unlock()
+	if rotator != nil && rotator.Filename == opts.File && rotator.MaxSize == size && rotator.MaxBackups == backups {
+		return rotator
+	}
+	if rotator != nil {
+		rotator.Close()
+	}
+	rotator = &lumberjack.Logger{
+		Filename:   opts.File,
+		MaxSize:    size,
+		MaxBackups: backups,
+	}
+	return rotator
+}
+
+func closeRotatingFile() {
+	rotatorMu.Lock()
+	defer rotatorMu.Unlock()
+	if rotator != nil {
+		rotator.Close()
+		rotator = nil
+	}
+}
+
+// DisableColor turns colors off for all output, as when NO_COLOR is
+// set. It must be called before Configure.
+func DisableColor() {
+	color.NoColor = true
+}
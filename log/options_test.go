@@ -0,0 +1,51 @@
+package log
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestConfigureKeepsLogFile(t *testing.T) {
+	defer func(l *zap.Logger) { GlobalLogger = l }(GlobalLogger)
+	defer closeRotatingFile()
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.log")
+
+	if err := Configure(Options{File: first}); err != nil {
+		t.Fatal(err)
+	}
+	r := rotator
+	Logger().Info("one")
+	if err := Configure(Options{File: first, Level: "debug"}); err != nil {
+		t.Fatal(err)
+	}
+	if rotator != r {
+		t.Fatal("reconfiguring with the same file opened a new rotator")
+	}
+	Logger().Info("two")
+
+	if err := Configure(Options{File: filepath.Join(dir, "second.log")}); err != nil {
+		t.Fatal(err)
+	}
+	if rotator == r {
+		t.Fatal("rotator kept after the file changed")
+	}
+	if err := Configure(Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if rotator != nil {
+		t.Fatal("rotator kept after file logging stopped")
+	}
+
+	data, err := os.ReadFile(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "one") || !strings.Contains(string(data), "two") {
+		t.Errorf("log file missing entries:\n%s", data)
+	}
+}
@@ -0,0 +1,163 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	fileKDFIterations = 600_000
+	fileSaltSize      = 16
+)
+
+// PassphraseEnv names the environment variable FileStore reads its
+// passphrase from when no Passphrase func is set.
+const PassphraseEnv = "CVTCLI_PASSPHRASE"
+
+// ErrNoPassphrase is returned when FileStore needs a passphrase and
+// none is available.
+var ErrNoPassphrase = errors.New("no passphrase: set " + PassphraseEnv + " or run in a terminal")
+
+// FileStore keeps secrets in a single file, each encrypted with
+// AES-256-GCM under a key derived from a passphrase with PBKDF2. The
+// file is created with mode 0600.
+type FileStore struct {
+	path string
+	// Passphrase supplies the passphrase, e.g. by prompting on a TTY.
+	// When nil, PassphraseEnv is used.
+	Passphrase func() (string, error)
+}
+
+type fileEntry struct {
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+func (f *FileStore) Name() string { return "encrypted file " + f.path }
+
+func (f *FileStore) passphrase() (string, error) {
+	if f.Passphrase != nil {
+		return f.Passphrase()
+	}
+	if p := os.Getenv(PassphraseEnv); p != "" {
+		return p, nil
+	}
+	return "", ErrNoPassphrase
+}
+
+func (f *FileStore) load() (map[string]fileEntry, error) {
+	entries := map[string]fileEntry{}
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return entries, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("%s: %w", f.path, err)
+	}
+	return entries, nil
+}
+
+func (f *FileStore) save(entries map[string]fileEntry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0700); err != nil {
+		return err
+	}
+	tmp := f.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, f.path)
+}
+
+func (f *FileStore) Get(account string) (string, error) {
+	entries, err := f.load()
+	if err != nil {
+		return "", err
+	}
+	e, ok := entries[account]
+	if !ok {
+		return "", ErrNotFound
+	}
+	pass, err := f.passphrase()
+	if err != nil {
+		return "", err
+	}
+	gcm, err := newGCM(pass, e.Salt)
+	if err != nil {
+		return "", err
+	}
+	plain, err := gcm.Open(nil, e.Nonce, e.Ciphertext, []byte(account))
+	if err != nil {
+		return "", errors.New("cannot decrypt secret: wrong passphrase?")
+	}
+	return string(plain), nil
+}
+
+func (f *FileStore) Set(account, secret string) error {
+	entries, err := f.load()
+	if err != nil {
+		return err
+	}
+	pass, err := f.passphrase()
+	if err != nil {
+		return err
+	}
+	e := fileEntry{Salt: make([]byte, fileSaltSize)}
+	if _, err := rand.Read(e.Salt); err != nil {
+		return err
+	}
+	gcm, err := newGCM(pass, e.Salt)
+	if err != nil {
+		return err
+	}
+	e.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(e.Nonce); err != nil {
+		return err
+	}
+	e.Ciphertext = gcm.Seal(nil, e.Nonce, []byte(secret), []byte(account))
+	entries[account] = e
+	return f.save(entries)
+}
+
+func (f *FileStore) Delete(account string) error {
+	entries, err := f.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := entries[account]; !ok {
+		return ErrNotFound
+	}
+	delete(entries, account)
+	return f.save(entries)
+}
+
+func newGCM(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, fileKDFIterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,34 @@
+package secret
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	s := NewFileStore(filepath.Join(t.TempDir(), "tokens.json"))
+	s.Passphrase = func() (string, error) { return "hunter2", nil }
+
+	if _, err := s.Get("work"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := s.Set("work", "tok-123"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.Get("work"); err != nil || got != "tok-123" {
+		t.Fatalf("got %q, %v", got, err)
+	}
+
+	s.Passphrase = func() (string, error) { return "wrong", nil }
+	if _, err := s.Get("work"); err == nil {
+		t.Fatal("decrypting with the wrong passphrase must fail")
+	}
+
+	if err := s.Delete("work"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Get("work"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after delete, got %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+// Package secret stores API tokens outside of config.yaml: in the
+// desktop keyring through the freedesktop Secret Service API, or in a
+// passphrase-encrypted file where no keyring is running (servers,
+// containers).
+package secret
+
+import "errors"
+
+// ErrNotFound is returned by Get and Delete when no secret is stored
+// for the account.
+var ErrNotFound = errors.New("secret not found")
+
+// Store keeps one secret per account. cvtcli uses the profile name as
+// the account.
+type Store interface {
+	Name() string
+	Get(account string) (string, error)
+	Set(account, secret string) error
+	Delete(account string) error
+}
+
+// Open returns the Secret Service keyring when one answers on the
+// session bus and the encrypted file store at fallbackPath otherwise.
+func Open(fallbackPath string) Store {
+	if ss, err := NewSecretService(); err == nil {
+		return ss
+	}
+	return NewFileStore(fallbackPath)
+}
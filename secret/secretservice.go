@@ -0,0 +1,183 @@
+package secret
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	ssDest           = "org.freedesktop.secrets"
+	ssPath           = "/org/freedesktop/secrets"
+	ssService        = "org.freedesktop.Secret.Service"
+	ssCollection     = "org.freedesktop.Secret.Collection"
+	ssItem           = "org.freedesktop.Secret.Item"
+	ssPrompt         = "org.freedesktop.Secret.Prompt"
+	ssDefaultAlias   = "/org/freedesktop/secrets/aliases/default"
+	ssAttrService    = "service"
+	ssAttrAccount    = "account"
+	ssServiceName    = "cvtcli"
+	ssAlgorithmPlain = "plain"
+)
+
+// ssSecret mirrors the (oayays) Secret struct of the Secret Service API.
+type ssSecret struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+// SecretService talks to gnome-keyring, KWallet or KeePassXC through
+// the org.freedesktop.secrets D-Bus API. Secrets travel over the
+// session bus with the "plain" algorithm, which is local to the user's
+// login session.
+type SecretService struct {
+	conn    *dbus.Conn
+	session dbus.ObjectPath
+}
+
+// NewSecretService connects to the session bus and opens a Secret
+// Service session. It fails when no keyring daemon is available. A
+// session bus that can't be found is not started: dbus.SessionBus would
+// run dbus-launch, leaving a bus daemon behind on every headless run.
+func NewSecretService() (*SecretService, error) {
+	conn, err := dbus.SessionBusPrivateNoAutoStartup()
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Auth(nil); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := conn.Hello(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	var (
+		output  dbus.Variant
+		session dbus.ObjectPath
+	)
+	err = conn.Object(ssDest, ssPath).
+		Call(ssService+".OpenSession", 0, ssAlgorithmPlain, dbus.MakeVariant("")).
+		Store(&output, &session)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("secret service: %w", err)
+	}
+	return &SecretService{conn: conn, session: session}, nil
+}
+
+func (s *SecretService) Name() string { return "keyring (Secret Service)" }
+
+func (s *SecretService) attributes(account string) map[string]string {
+	return map[string]string{ssAttrService: ssServiceName, ssAttrAccount: account}
+}
+
+// find returns the item holding account's secret, unlocking it when
+// the keyring is locked.
+func (s *SecretService) find(account string) (dbus.ObjectPath, error) {
+	var unlocked, locked []dbus.ObjectPath
+	err := s.conn.Object(ssDest, ssPath).
+		Call(ssService+".SearchItems", 0, s.attributes(account)).
+		Store(&unlocked, &locked)
+	if err != nil {
+		return "", err
+	}
+	if len(unlocked) > 0 {
+		return unlocked[0], nil
+	}
+	if len(locked) == 0 {
+		return "", ErrNotFound
+	}
+	if err := s.unlock(locked[:1]); err != nil {
+		return "", err
+	}
+	return locked[0], nil
+}
+
+func (s *SecretService) unlock(objects []dbus.ObjectPath) error {
+	var (
+		unlocked []dbus.ObjectPath
+		prompt   dbus.ObjectPath
+	)
+	err := s.conn.Object(ssDest, ssPath).
+		Call(ssService+".Unlock", 0, objects).
+		Store(&unlocked, &prompt)
+	if err != nil {
+		return err
+	}
+	return s.prompt(prompt)
+}
+
+// prompt runs a Secret Service prompt (e.g. the keyring password
+// dialog) and waits for the user to answer it. "/" means no prompt is
+// needed.
+func (s *SecretService) prompt(path dbus.ObjectPath) error {
+	if path == "/" || path == "" {
+		return nil
+	}
+	signals := make(chan *dbus.Signal, 1)
+	s.conn.Signal(signals)
+	defer s.conn.RemoveSignal(signals)
+	match := []dbus.MatchOption{dbus.WithMatchObjectPath(path), dbus.WithMatchInterface(ssPrompt)}
+	if err := s.conn.AddMatchSignal(match...); err != nil {
+		return err
+	}
+	defer s.conn.RemoveMatchSignal(match...)
+
+	if err := s.conn.Object(ssDest, path).Call(ssPrompt+".Prompt", 0, "").Err; err != nil {
+		return err
+	}
+	for sig := range signals {
+		if sig.Path != path || sig.Name != ssPrompt+".Completed" || len(sig.Body) == 0 {
+			continue
+		}
+		if dismissed, _ := sig.Body[0].(bool); dismissed {
+			return errors.New("secret service: prompt dismissed")
+		}
+		return nil
+	}
+	return errors.New("secret service: connection closed while prompting")
+}
+
+func (s *SecretService) Get(account string) (string, error) {
+	item, err := s.find(account)
+	if err != nil {
+		return "", err
+	}
+	var secret ssSecret
+	if err := s.conn.Object(ssDest, item).Call(ssItem+".GetSecret", 0, s.session).Store(&secret); err != nil {
+		return "", fmt.Errorf("secret service: %w", err)
+	}
+	return string(secret.Value), nil
+}
+
+func (s *SecretService) Set(account, value string) error {
+	props := map[string]dbus.Variant{
+		ssItem + ".Label":      dbus.MakeVariant("cvtcli API token (" + account + ")"),
+		ssItem + ".Attributes": dbus.MakeVariant(s.attributes(account)),
+	}
+	secret := ssSecret{Session: s.session, Value: []byte(value), ContentType: "text/plain"}
+	var item, prompt dbus.ObjectPath
+	err := s.conn.Object(ssDest, ssDefaultAlias).
+		Call(ssCollection+".CreateItem", 0, props, secret, true).
+		Store(&item, &prompt)
+	if err != nil {
+		return fmt.Errorf("secret service: %w", err)
+	}
+	return s.prompt(prompt)
+}
+
+func (s *SecretService) Delete(account string) error {
+	item, err := s.find(account)
+	if err != nil {
+		return err
+	}
+	var prompt dbus.ObjectPath
+	if err := s.conn.Object(ssDest, item).Call(ssItem+".Delete", 0).Store(&prompt); err != nil {
+		return fmt.Errorf("secret service: %w", err)
+	}
+	return s.prompt(prompt)
+}
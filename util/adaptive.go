@@ -0,0 +1,350 @@
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// tuneWindow is how long the throughput of a setting is measured.
+	tuneWindow = 3 * time.Second
+	// tuneGain is the improvement that makes another connection worth it.
+	tuneGain = 1.1
+	// tuneRecheck is how often a settled Tuner tries one connection more,
+	// in case the network got faster.
+	tuneRecheck = 30 * time.Second
+	// tuneStart is the number of connections tried on an unknown host.
+	tuneStart = 2
+	// tuningTTL is how long a remembered setting is trusted.
+	tuningTTL = 30 * 24 * time.Hour
+)
+
+// Tuner adapts the number of chunk requests a download has in flight.
+// The downloader runs with the maximum number of workers and the Tuner
+// holds back all but limit of their requests. It starts small, or at
+// the setting remembered for the host, and keeps adding connections
+// while aggregate throughput grows by at least 10%; once it plateaus it
+// returns to the best level. A 429 or 503 from the server counts as
+// throttling and takes a connection away. The best setting is
+// remembered per host in CacheDir.
+type Tuner struct {
+	host string
+	max  int
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	limit  int
+	active int
+	peak   int // most requests in flight this window
+
+	bytes     atomic.Int64
+	throttled atomic.Bool
+
+	// Owned by run.
+	bestLimit int
+	bestRate  float64 // bytes/s
+	settled   bool
+	skip      bool // the window after a change is ramp-up
+	probed    time.Time
+	measured  int
+
+	quit chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// Tuning is the setting remembered for a host.
+type Tuning struct {
+	Threads    int       `json:"threads"`
+	ChunkSize  int64     `json:"chunkSize"`
+	Throughput float64   `json:"throughput"` // bytes/s
+	Updated    time.Time `json:"updated"`
+}
+
+// NewTuner starts tuning a download of rawURL with at most ceiling
+// connections. The host is that of the storage URL rawURL redirects to
+// when a probe has resolved it.
+func NewTuner(rawURL string, ceiling int) *Tuner {
+	t := &Tuner{
+		host:  tuneHost(rawURL),
+		max:   ceiling,
+		limit: min(tuneStart, ceiling),
+		quit:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	t.cond = sync.NewCond(&t.mu)
+	if rec, ok := LoadTuning(t.host); ok {
+		t.limit = min(max(rec.Threads, 1), t.max)
+	}
+	t.bestLimit = t.limit
+	t.skip = true
+	go t.run()
+	return t
+}
+
+func tuneHost(rawURL string) string {
+	signedMu.Lock()
+	s := signedURLs[rawURL]
+	signedMu.Unlock()
+	if s != nil {
+		return s.url.Host
+	}
+	if u, err := url.Parse(rawURL); err == nil {
+		return u.Host
+	}
+	return rawURL
+}
+
+// ChunkSize is the chunk size remembered for the host, or 0.
+func (t *Tuner) ChunkSize() int64 {
+	if rec, ok := LoadTuning(t.host); ok {
+		return rec.ChunkSize
+	}
+	return 0
+}
+
+// Result is the best setting found and its throughput in bytes/s.
+// Call it after Close.
+func (t *Tuner) Result() (threads int, rate float64) {
+	return t.bestLimit, t.bestRate
+}
+
+func (t *Tuner) run() {
+	defer close(t.done)
+	tick := time.NewTicker(tuneWindow)
+	defer tick.Stop()
+	for {
+		select {
+		case <-tick.C:
+			t.adjust(float64(t.bytes.Swap(0)) / tuneWindow.Seconds())
+		case <-t.quit:
+			return
+		}
+	}
+}
+
+// adjust takes the throughput of the last window and picks the limit
+// for the next.
+func (t *Tuner) adjust(rate float64) {
+	t.mu.Lock()
+	limit, peak := t.limit, t.peak
+	t.peak = t.active
+	t.mu.Unlock()
+
+	if t.throttled.Swap(false) {
+		t.bestLimit = max(1, min(limit-1, t.bestLimit))
+		t.settled, t.probed = true, time.Now()
+		t.setLimit(t.bestLimit)
+		return
+	}
+	if t.skip || rate == 0 {
+		t.skip = false
+		return
+	}
+	t.measured++
+	// Fewer requests than allowed were running (the download is
+	// ending, or waits on the rate limit): the window says nothing
+	// about this level.
+	if peak < limit {
+		return
+	}
+
+	switch {
+	case rate >= t.bestRate*tuneGain:
+		t.bestLimit, t.bestRate = limit, rate
+		if limit < t.max {
+			t.settled = false
+			t.setLimit(min(limit+max(1, limit/2), t.max))
+		} else {
+			t.settled, t.probed = true, time.Now()
+		}
+	case t.settled && limit == t.bestLimit:
+		// Follow the network, so a later probe competes with the
+		// current rate rather than a past peak.
+		t.bestRate = rate
+		if limit < t.max && time.Since(t.probed) >= tuneRecheck {
+			t.probed = time.Now()
+			t.setLimit(limit + 1)
+		}
+	default:
+		// No gain from the last step: the plateau is at bestLimit.
+		t.settled, t.probed = true, time.Now()
+		t.setLimit(t.bestLimit)
+	}
+}
+
+func (t *Tuner) setLimit(n int) {
+	t.mu.Lock()
+	if n != t.limit {
+		t.limit = n
+		t.skip = true
+		t.cond.Broadcast()
+	}
+	t.mu.Unlock()
+}
+
+// acquire waits for a free connection slot.
+func (t *Tuner) acquire(ctx context.Context) error {
+	stop := context.AfterFunc(ctx, func() {
+		t.mu.Lock()
+		t.cond.Broadcast()
+		t.mu.Unlock()
+	})
+	defer stop()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for t.active >= t.limit {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		t.cond.Wait()
+	}
+	t.active++
+	t.peak = max(t.peak, t.active)
+	return nil
+}
+
+func (t *Tuner) release() {
+	t.mu.Lock()
+	t.active--
+	t.cond.Signal()
+	t.mu.Unlock()
+}
+
+// Close stops tuning and remembers the best setting for the host, with
+// a chunk size that keeps one connection busy for about eight seconds.
+func (t *Tuner) Close() {
+	t.once.Do(func() {
+		close(t.quit)
+		<-t.done
+		if t.measured < 2 || t.bestRate == 0 {
+			return
+		}
+		chunk := int64(t.bestRate/float64(t.bestLimit)*8) &^ (1<<20 - 1)
+		SaveTuning(t.host, Tuning{
+			Threads:    t.bestLimit,
+			ChunkSize:  min(max(chunk, 4<<20), 128<<20),
+			Throughput: t.bestRate,
+			Updated:    time.Now(),
+		})
+	})
+}
+
+var (
+	tunersMu sync.Mutex
+	tuners   = map[string]*Tuner{}
+)
+
+// Watch puts the shared client's requests for url under t until the
+// returned function is called.
+func (t *Tuner) Watch(url string) func() {
+	tunersMu.Lock()
+	tuners[url] = t
+	tunersMu.Unlock()
+	return func() {
+		tunersMu.Lock()
+		if tuners[url] == t {
+			delete(tuners, url)
+		}
+		tunersMu.Unlock()
+	}
+}
+
+// tuneTransport holds each request of a tuned URL until its Tuner has
+// a slot for it, and keeps the slot until the body is closed.
+type tuneTransport struct {
+	base http.RoundTripper
+}
+
+func (tt *tuneTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	tunersMu.Lock()
+	t := tuners[req.URL.String()]
+	tunersMu.Unlock()
+	if t == nil || req.Method != http.MethodGet {
+		return tt.base.RoundTrip(req)
+	}
+	if err := t.acquire(req.Context()); err != nil {
+		return nil, err
+	}
+	resp, err := tt.base.RoundTrip(req)
+	if err != nil {
+		t.release()
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		t.throttled.Store(true)
+	}
+	resp.Body = &tunedBody{ReadCloser: resp.Body, t: t}
+	return resp, nil
+}
+
+type tunedBody struct {
+	io.ReadCloser
+	t    *Tuner
+	once sync.Once
+}
+
+func (b *tunedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.t.bytes.Add(int64(n))
+	return n, err
+}
+
+func (b *tunedBody) Close() error {
+	b.once.Do(b.t.release)
+	return b.ReadCloser.Close()
+}
+
+var tuningMu sync.Mutex
+
+func tuningPath() string {
+	return filepath.Join(CacheDir(), "tuning.json")
+}
+
+func readTunings() map[string]Tuning {
+	m := map[string]Tuning{}
+	if data, err := os.ReadFile(tuningPath()); err == nil {
+		json.Unmarshal(data, &m)
+	}
+	return m
+}
+
+// LoadTuning returns the setting remembered for host, unless it is
+// older than a month.
+func LoadTuning(host string) (Tuning, bool) {
+	tuningMu.Lock()
+	defer tuningMu.Unlock()
+	rec, ok := readTunings()[host]
+	if !ok || time.Since(rec.Updated) > tuningTTL || rec.Threads <= 0 {
+		return Tuning{}, false
+	}
+	return rec, true
+}
+
+// SaveTuning remembers rec for host.
+func SaveTuning(host string, rec Tuning) error {
+	tuningMu.Lock()
+	defer tuningMu.Unlock()
+	m := readTunings()
+	m[host] = rec
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(CacheDir(), 0755); err != nil {
+		return err
+	}
+	tmp := tuningPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, tuningPath())
+}
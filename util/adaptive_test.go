@@ -0,0 +1,80 @@
+package util
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTunerFindsPlateau(t *testing.T) {
+	tu := &Tuner{max: 32, limit: 2, bestLimit: 2, skip: true}
+	tu.cond = sync.NewCond(&tu.mu)
+	// Throughput grows with connections up to 6, then stays flat.
+	rate := func(n int) float64 { return float64(min(n, 6)) * 1e6 }
+	for range 20 {
+		tu.active, tu.peak = tu.limit, tu.limit
+		tu.adjust(rate(tu.limit))
+	}
+	if tu.limit != 6 || tu.bestLimit != 6 || !tu.settled {
+		t.Errorf("limit %d, best %d, settled %v; want 6", tu.limit, tu.bestLimit, tu.settled)
+	}
+
+	tu.throttled.Store(true)
+	tu.adjust(rate(tu.limit))
+	if tu.limit != 5 {
+		t.Errorf("limit %d after throttling, want 5", tu.limit)
+	}
+}
+
+func TestTuningRemembered(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	if _, ok := LoadTuning("cdn.example.com"); ok {
+		t.Fatal("tuning for an unknown host")
+	}
+	tu := &Tuner{host: "cdn.example.com", bestLimit: 6, bestRate: 60 << 20, measured: 3, quit: make(chan struct{}), done: make(chan struct{})}
+	close(tu.done)
+	tu.Close()
+	rec, ok := LoadTuning("cdn.example.com")
+	if !ok || rec.Threads != 6 || rec.ChunkSize != 80<<20 {
+		t.Errorf("got %+v, %v", rec, ok)
+	}
+}
+
+func TestTunerLimitsRequests(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	var inFlight, peak atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("chunk"))
+	}))
+	defer srv.Close()
+
+	tu := NewTuner(srv.URL, 8)
+	defer tu.Close()
+	defer tu.Watch(srv.URL)()
+	client := GetHttpClient().GetRawClient()
+	var wg sync.WaitGroup
+	for range 6 {
+		wg.Go(func() {
+			resp, err := client.Get(srv.URL)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		})
+	}
+	wg.Wait()
+	if p := peak.Load(); p != tuneStart {
+		t.Errorf("%d requests in flight, want %d", p, tuneStart)
+	}
+}
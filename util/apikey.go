@@ -1,3 +1,45 @@
 package util
 
-var AuthHeader map[string]string
+import "sync"
+
+var (
+	tokenMu     sync.Mutex
+	token       string
+	tokenSource func() string
+)
+
+// SetToken sets the API token sent with API and download requests; an
+// empty token runs anonymously.
+func SetToken(t string) {
+	tokenMu.Lock()
+	token, tokenSource = t, nil
+	tokenMu.Unlock()
+}
+
+// SetTokenSource defers looking up the token until a request needs it,
+// since the lookup may talk to the keyring or prompt for a passphrase.
+// source is called at most once.
+func SetTokenSource(source func() string) {
+	tokenMu.Lock()
+	token, tokenSource = "", source
+	tokenMu.Unlock()
+}
+
+// Token returns the API token, or "" when running anonymously.
+func Token() string {
+	tokenMu.Lock()
+	defer tokenMu.Unlock()
+	if tokenSource != nil {
+		token, tokenSource = tokenSource(), nil
+	}
+	return token
+}
+
+// AuthHeader returns the headers that authenticate a request. It is nil
+// without a token, so anonymous requests carry no Authorization.
+func AuthHeader() map[string]string {
+	if t := Token(); t != "" {
+		return map[string]string{"Authorization": "Bearer " + t}
+	}
+	return nil
+}
@@ -18,20 +18,15 @@ func FileExists(path string) bool {
 	return err == nil
 }
 
-func PreallocateFile(f *os.File, size int64) error {
+// ReserveFile sets aside size bytes of disk for f without changing its
+// length, so running out of space shows up now rather than halfway
+// through a download. The file still reads as empty, so nothing that
+// judges progress by its length is misled. Only Linux filesystems that
+// support fallocate with FALLOC_FL_KEEP_SIZE can do this; everywhere
+// else nothing is reserved and errors.ErrUnsupported is returned.
+func ReserveFile(f *os.File, size int64) error {
 	if size <= 0 {
 		return nil
 	}
-	if err := f.Truncate(size); err != nil {
-		if _, err := f.Seek(size-1, 0); err != nil {
-			return err
-		}
-		if _, err := f.Write([]byte{0}); err != nil {
-			return err
-		}
-		if _, err := f.Seek(0, 0); err != nil {
-			return err
-		}
-	}
-	return nil
+	return reserve(f, size)
 }
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
@@ -25,20 +26,35 @@ func TestFileExists(t *testing.T) {
 	}
 }
 
-func TestPreallocateFile(t *testing.T) {
-	dir := t.TempDir()
-	p := filepath.Join(dir, "prealloc.bin")
-	f, err := os.Create(p)
+func TestFreeSpace(t *testing.T) {
+	free, err := FreeSpace(t.TempDir())
+	if errors.Is(err, errors.ErrUnsupported) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if free == 0 {
+		t.Fatal("expected free space on the temp dir")
+	}
+}
+
+func TestReserveFile(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "reserved.bin"))
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer f.Close()
 
-	if err := PreallocateFile(f, 4096); err != nil {
+	err = ReserveFile(f, 1<<20)
+	if errors.Is(err, errors.ErrUnsupported) {
+		t.Skip(err)
+	}
+	if err != nil {
 		t.Fatal(err)
 	}
 	fi, _ := f.Stat()
-	if fi.Size() != 4096 {
-		t.Fatalf("expected 4096, got %d", fi.Size())
+	if fi.Size() != 0 {
+		t.Fatalf("length changed to %d", fi.Size())
 	}
 }
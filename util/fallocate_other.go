@@ -0,0 +1,12 @@
+//go:build !linux
+
+package util
+
+import (
+	"errors"
+	"os"
+)
+
+func reserve(f *os.File, size int64) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,94 @@
+package util
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxFilenameBytes keeps names below the 255-byte limit of common
+// filesystems, with room for the ".part" suffix of partial downloads.
+const maxFilenameBytes = 200
+
+// windowsReserved are device names Windows refuses as file names, with
+// or without an extension.
+var windowsReserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// SanitizeFilename turns a name supplied by a server or the API into a
+// single safe path element: separators and characters Windows forbids
+// become "_", control characters are dropped, leading dots and trailing
+// dots and spaces are trimmed (so "..", ".bashrc" tricks and hidden
+// files are out), reserved device names get a "_" prefix and the result
+// is cut to maxFilenameBytes, keeping the extension. An empty result is
+// returned as "_".
+func SanitizeFilename(name string) string {
+	name = strings.ToValidUTF8(name, "_")
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case unicode.IsControl(r):
+		case strings.ContainsRune(`/\:*?"<>|`, r):
+			b.WriteRune('_')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	name = strings.TrimLeft(b.String(), ". ")
+	name = strings.TrimRight(name, ". ")
+
+	stem, _, _ := strings.Cut(name, ".")
+	if windowsReserved[strings.ToUpper(strings.TrimSpace(stem))] {
+		name = "_" + name
+	}
+	name = truncateName(name, maxFilenameBytes)
+	if name == "" {
+		return "_"
+	}
+	return name
+}
+
+// truncateName cuts name to max bytes on a rune boundary, keeping a
+// short extension intact.
+func truncateName(name string, max int) string {
+	if len(name) <= max {
+		return name
+	}
+	ext := filepath.Ext(name)
+	if len(ext) > 16 {
+		ext = ""
+	}
+	stem := strings.TrimSuffix(name, ext)
+	limit := max - len(ext)
+	for len(stem) > limit {
+		_, size := utf8.DecodeLastRuneInString(stem)
+		stem = stem[:len(stem)-size]
+	}
+	return strings.TrimRight(stem, ". ") + ext
+}
+
+// WithinDir reports an error unless path, once cleaned, lies inside
+// dir. It is the last line of defense after sanitizing the elements
+// of a path built from untrusted names.
+func WithinDir(dir, path string) error {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+		return fmt.Errorf("refusing to write %s: outside of %s", path, dir)
+	}
+	return nil
+}
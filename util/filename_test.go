@@ -0,0 +1,50 @@
+package util
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	for in, want := range map[string]string{
+		"model.safetensors":    "model.safetensors",
+		"../../.bashrc":        "_.._.bashrc",
+		"/etc/passwd":          "_etc_passwd",
+		`..\..\evil.exe`:       `_.._evil.exe`,
+		"..":                   "_",
+		".hidden":              "hidden",
+		"a\x00b\nc.bin":        "abc.bin",
+		"CON.txt":              "_CON.txt",
+		"lpt1":                 "_lpt1",
+		"trailing. ":           "trailing",
+		"Ĺora v2: final?.ckpt": "Ĺora v2_ final_.ckpt",
+		"":                     "_",
+	} {
+		if got := SanitizeFilename(in); got != want {
+			t.Errorf("SanitizeFilename(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	long := strings.Repeat("é", 300) + ".safetensors"
+	got := SanitizeFilename(long)
+	if len(got) > maxFilenameBytes || !strings.HasSuffix(got, ".safetensors") {
+		t.Errorf("long name: %d bytes, %q", len(got), got[len(got)-20:])
+	}
+}
+
+func TestWithinDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := WithinDir(dir, filepath.Join(dir, "LORA", "x.safetensors")); err != nil {
+		t.Error(err)
+	}
+	for _, p := range []string{
+		filepath.Join(dir, "..", "x"),
+		dir,
+		"/etc/passwd",
+	} {
+		if err := WithinDir(dir, p); err == nil {
+			t.Errorf("%s: expected an error", p)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+
+	"lukechampine.com/blake3"
+)
+
+// FileSHA256 returns the hex-encoded SHA256 of the file at path.
+func FileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Sums are the digests of a file that the API publishes, hex-encoded.
+// BLAKE3 is empty when it couldn't be computed.
+type Sums struct {
+	SHA256 string
+	CRC32  string
+	BLAKE3 string
+}
+
+// FileSums hashes the file at path in a single pass.
+func FileSums(path string) (Sums, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Sums{}, err
+	}
+	defer f.Close()
+	h := newHashSet()
+	if _, err := io.Copy(h, f); err != nil {
+		return Sums{}, err
+	}
+	return h.sums(), nil
+}
+
+// hashSet feeds everything written to it to each digest of Sums.
+type hashSet struct {
+	sha    hash.Hash
+	crc    hash.Hash32
+	blake3 *blake3.Hasher // nil when left out
+}
+
+func newHashSet() *hashSet {
+	return &hashSet{sha: sha256.New(), crc: crc32.NewIEEE(), blake3: blake3.New(32, nil)}
+}
+
+func (h *hashSet) Write(p []byte) (int, error) {
+	h.sha.Write(p)
+	h.crc.Write(p)
+	if h.blake3 != nil {
+		h.blake3.Write(p)
+	}
+	return len(p), nil
+}
+
+func (h *hashSet) sums() Sums {
+	s := Sums{
+		SHA256: hex.EncodeToString(h.sha.Sum(nil)),
+		CRC32:  fmt.Sprintf("%08x", h.crc.Sum32()),
+	}
+	if h.blake3 != nil {
+		s.BLAKE3 = hex.EncodeToString(h.blake3.Sum(nil))
+	}
+	return s
+}
@@ -0,0 +1,39 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+)
+
+const appDirName = "cvtcli"
+
+// ConfigDir returns the directory holding config.yaml. The legacy
+// ~/.cvtcli is used while it exists; otherwise the XDG base directory
+// spec applies ($XDG_CONFIG_HOME/cvtcli, default ~/.config/cvtcli).
+func ConfigDir() string {
+	home, _ := os.UserHomeDir()
+	if home != "" {
+		legacy := filepath.Join(home, "."+appDirName)
+		if FileExists(filepath.Join(legacy, "config.yaml")) {
+			return legacy
+		}
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, appDirName)
+	}
+	if dir, err := os.UserConfigDir(); err == nil {
+		return filepath.Join(dir, appDirName)
+	}
+	return filepath.Join(home, "."+appDirName)
+}
+
+// CacheDir returns $XDG_CACHE_HOME/cvtcli (default ~/.cache/cvtcli).
+func CacheDir() string {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, appDirName)
+	}
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, appDirName)
+	}
+	return filepath.Join(os.TempDir(), appDirName)
+}
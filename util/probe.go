@@ -0,0 +1,174 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// probeTTL is how long a probe answers the downloader's own probe of
+// the same URL. It only has to bridge the gap between resolving a
+// download and starting it.
+const probeTTL = 30 * time.Second
+
+// Probe is what a single ranged GET learned about a download.
+type Probe struct {
+	// URL is the final URL after redirects, typically a signed CDN URL.
+	URL string
+	// Size is the full length in bytes, 0 when the server didn't say.
+	Size         int64
+	ETag         string
+	AcceptRanges bool
+	// Header is the header of the final response.
+	Header http.Header
+
+	head    []byte // first bytes of the file
+	expires time.Time
+}
+
+var (
+	probesMu sync.Mutex
+	probes   = map[string]*Probe{}
+)
+
+// ProbeURL issues one "Range: bytes=0-1" GET for rawURL, following
+// redirects, and reports what it found. Errors from the server come
+// back as *HTTPError. A successful probe is remembered for a short
+// while, so the downloader's own probe of rawURL is answered without
+// another round-trip, and the final URL it redirected to is used for
+// the downloader's requests of rawURL from then on.
+func ProbeURL(ctx context.Context, rawURL string, headers map[string]string) (*Probe, error) {
+	ctx = context.WithValue(ctx, directKey{}, true)
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Range", "bytes=0-1")
+	resp, err := GetHttpClient().GetRawClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, &HTTPError{Code: resp.StatusCode, Body: string(body), RetryAfter: resp.Header.Get("Retry-After")}
+	}
+	head, _ := io.ReadAll(io.LimitReader(resp.Body, 2))
+
+	p := &Probe{
+		URL:          resp.Request.URL.String(),
+		ETag:         resp.Header.Get("ETag"),
+		AcceptRanges: resp.StatusCode == http.StatusPartialContent || resp.Header.Get("Accept-Ranges") == "bytes",
+		Header:       resp.Header,
+		head:         head,
+		expires:      time.Now().Add(probeTTL),
+	}
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		p.Size = rangeTotal(resp.Header.Get("Content-Range"))
+	case http.StatusOK:
+		if resp.ContentLength > 0 {
+			p.Size = resp.ContentLength
+		}
+	}
+	rememberSigned(rawURL, p.URL)
+	if p.Size > 0 {
+		probesMu.Lock()
+		probes[rawURL] = p
+		probesMu.Unlock()
+	}
+	return p, nil
+}
+
+// rangeTotal is the complete length of a "bytes 0-1/12345"
+// Content-Range, or 0.
+func rangeTotal(cr string) int64 {
+	_, total, ok := strings.Cut(cr, "/")
+	if !ok {
+		return 0
+	}
+	n, err := strconv.ParseInt(total, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+func lookupProbe(url string) *Probe {
+	probesMu.Lock()
+	defer probesMu.Unlock()
+	p := probes[url]
+	if p != nil && time.Now().After(p.expires) {
+		delete(probes, url)
+		return nil
+	}
+	return p
+}
+
+// probeTransport answers probes of a URL that ProbeURL just resolved: a
+// HEAD, or a GET for the first byte or two. The response carries the
+// headers of the final URL, but no redirect.
+type probeTransport struct {
+	base http.RoundTripper
+}
+
+func (t *probeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if resp := cachedProbe(req); resp != nil {
+		return resp, nil
+	}
+	return t.base.RoundTrip(req)
+}
+
+func cachedProbe(req *http.Request) *http.Response {
+	if req.Method != http.MethodHead && req.Method != http.MethodGet || req.Context().Value(directKey{}) != nil {
+		return nil
+	}
+	p := lookupProbe(req.URL.String())
+	if p == nil {
+		return nil
+	}
+
+	header := p.Header.Clone()
+	header.Del("Content-Range")
+	if p.AcceptRanges {
+		header.Set("Accept-Ranges", "bytes")
+	}
+	resp := &http.Response{
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     header,
+		Request:    req,
+	}
+	switch {
+	case req.Method == http.MethodHead:
+		resp.StatusCode = http.StatusOK
+		resp.ContentLength = p.Size
+		resp.Body = http.NoBody
+	case p.AcceptRanges:
+		var last int
+		if _, err := fmt.Sscanf(req.Header.Get("Range"), "bytes=0-%d", &last); err != nil || last < 0 || last >= len(p.head) {
+			return nil
+		}
+		body := p.head[:last+1]
+		resp.StatusCode = http.StatusPartialContent
+		resp.ContentLength = int64(len(body))
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		header.Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", last, p.Size))
+	default:
+		return nil
+	}
+	resp.Status = fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode))
+	header.Set("Content-Length", strconv.FormatInt(resp.ContentLength, 10))
+	return resp
+}
@@ -0,0 +1,76 @@
+package util
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProbeURLAnswersDownloaderProbe(t *testing.T) {
+	content := strings.Repeat("0123456789", 100)
+	var cdnHits atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/download/models/1", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/cdn/model.safetensors?sig=abc", http.StatusFound)
+	})
+	mux.HandleFunc("/cdn/model.safetensors", func(w http.ResponseWriter, r *http.Request) {
+		cdnHits.Add(1)
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Disposition", `attachment; filename="model.safetensors"`)
+		http.ServeContent(w, r, "model.safetensors", time.Time{}, strings.NewReader(content))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	source := srv.URL + "/api/download/models/1"
+	p, err := ProbeURL(context.Background(), source, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.URL != srv.URL+"/cdn/model.safetensors?sig=abc" || p.Size != int64(len(content)) || p.ETag != `"v1"` || !p.AcceptRanges {
+		t.Fatalf("probe = %+v", p)
+	}
+
+	client := GetHttpClient().GetRawClient()
+	head, err := client.Head(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head.Body.Close()
+	if head.ContentLength != int64(len(content)) || head.Header.Get("ETag") != `"v1"` {
+		t.Errorf("HEAD = %d %v", head.ContentLength, head.Header)
+	}
+
+	req, _ := http.NewRequest("GET", source, nil)
+	req.Header.Set("Range", "bytes=0-0")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent || string(b) != "0" || resp.Header.Get("Content-Range") != "bytes 0-0/1000" {
+		t.Errorf("range probe = %d %q %v", resp.StatusCode, b, resp.Header)
+	}
+	if n := cdnHits.Load(); n != 1 {
+		t.Errorf("CDN hit %d times, want 1", n)
+	}
+
+	// Chunk requests of the source URL go straight to the CDN.
+	req, _ = http.NewRequest("GET", source, nil)
+	req.Header.Set("Range", "bytes=10-19")
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(b) != "0123456789" || cdnHits.Load() != 2 {
+		t.Errorf("chunk = %q after %d hits", b, cdnHits.Load())
+	}
+}
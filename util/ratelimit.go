@@ -0,0 +1,117 @@
+package util
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxLimitedRead caps a single read through the limiter, so one chunk
+// worker can't take a whole second's budget in one go while the others
+// wait.
+const maxLimitedRead = 32 << 10
+
+// Limiter is a token bucket measured in bytes. A single Limiter shared
+// by every response body caps the total rate of all concurrent
+// downloads. The zero value is unlimited.
+type Limiter struct {
+	mu      sync.Mutex
+	rate    func(time.Time) int64
+	cur     int64
+	checked time.Time
+	tokens  float64
+	last    time.Time
+}
+
+// downloadLimiter throttles everything read through the shared client.
+var downloadLimiter = &Limiter{}
+
+// SetRateLimit sets the bandwidth cap of the shared client. rate is
+// asked for the bytes per second allowed at a given time, with 0
+// meaning unlimited; it is consulted about once a second, so a
+// schedule takes effect without restarting a download. A nil rate
+// removes the cap.
+func SetRateLimit(rate func(time.Time) int64) {
+	downloadLimiter.mu.Lock()
+	defer downloadLimiter.mu.Unlock()
+	downloadLimiter.rate = rate
+	downloadLimiter.checked = time.Time{}
+}
+
+// WaitN blocks until n bytes may pass, or ctx is done.
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	if now.Sub(l.checked) >= time.Second {
+		l.checked = now
+		l.cur = 0
+		if l.rate != nil {
+			l.cur = l.rate(now)
+		}
+	}
+	if l.cur <= 0 {
+		l.mu.Unlock()
+		return nil
+	}
+	rate := float64(l.cur)
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * rate
+	}
+	l.last = now
+	if l.tokens > rate {
+		// Allow at most one second of burst.
+		l.tokens = rate
+	}
+	// Take the bytes now and go into debt if needed; later callers
+	// then queue behind this one.
+	l.tokens -= float64(n)
+	wait := time.Duration(-l.tokens / rate * float64(time.Second))
+	l.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// limitTransport throttles the bodies of responses.
+type limitTransport struct {
+	base    http.RoundTripper
+	limiter *Limiter
+}
+
+func (t *limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &limitedBody{ReadCloser: resp.Body, ctx: req.Context(), limiter: t.limiter}
+	return resp, nil
+}
+
+type limitedBody struct {
+	io.ReadCloser
+	ctx     context.Context
+	limiter *Limiter
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if len(p) > maxLimitedRead {
+		p = p[:maxLimitedRead]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		if werr := b.limiter.WaitN(b.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
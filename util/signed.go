@@ -0,0 +1,147 @@
+package util
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// signedURLMargin renews a signed URL this long before it expires, so
+// a chunk request doesn't start on a signature about to lapse.
+const signedURLMargin = 30 * time.Second
+
+// signedURL is the current storage URL behind a stable download URL.
+type signedURL struct {
+	url     *url.URL
+	expires time.Time // zero when the URL doesn't say
+}
+
+var (
+	signedMu   sync.Mutex
+	signedURLs = map[string]*signedURL{}
+)
+
+// directKey marks requests that must go to the network for the URL
+// as given, bypassing the probe cache and signed URLs: the probes
+// themselves.
+type directKey struct{}
+
+// rememberSigned records that source currently redirects to final.
+func rememberSigned(source, final string) {
+	if source == final {
+		return
+	}
+	u, err := url.Parse(final)
+	if err != nil {
+		return
+	}
+	signedMu.Lock()
+	signedURLs[source] = &signedURL{url: u, expires: signatureExpiry(u)}
+	signedMu.Unlock()
+}
+
+// signatureExpiry reads the expiry of a pre-signed URL: S3 style
+// X-Amz-Date plus X-Amz-Expires, or a Unix "Expires" as used by
+// CloudFront and GCS.
+func signatureExpiry(u *url.URL) time.Time {
+	q := u.Query()
+	if d, s := q.Get("X-Amz-Date"), q.Get("X-Amz-Expires"); d != "" && s != "" {
+		start, err := time.Parse("20060102T150405Z", d)
+		secs, err2 := strconv.Atoi(s)
+		if err == nil && err2 == nil {
+			return start.Add(time.Duration(secs) * time.Second)
+		}
+	}
+	if e := q.Get("Expires"); e != "" {
+		if secs, err := strconv.ParseInt(e, 10, 64); err == nil {
+			return time.Unix(secs, 0)
+		}
+	}
+	return time.Time{}
+}
+
+// signedTransport lets the downloader work with the stable download
+// URL (api/download/models/{id}) while requests go straight to the
+// storage URL it redirects to. When that pre-signed URL has expired,
+// or is about to, or the storage host rejects it with 400/403, the
+// stable URL is resolved again and the request retried on the new
+// signature, so a long or resumed download continues where it was.
+type signedTransport struct {
+	base http.RoundTripper
+}
+
+func (t *signedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Context().Value(directKey{}) != nil {
+		return t.base.RoundTrip(req)
+	}
+	source := req.URL.String()
+	signedMu.Lock()
+	s := signedURLs[source]
+	signedMu.Unlock()
+	if s == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	if !s.expires.IsZero() && time.Until(s.expires) < signedURLMargin {
+		if fresh, err := renewSigned(req, source, s); err == nil {
+			s = fresh
+		}
+	}
+	resp, err := t.base.RoundTrip(signedRequest(req, s.url))
+	if err != nil || (resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusBadRequest) {
+		return resp, err
+	}
+
+	fresh, rerr := renewSigned(req, source, s)
+	if rerr != nil {
+		return resp, nil
+	}
+	resp.Body.Close()
+	return t.base.RoundTrip(signedRequest(req, fresh.url))
+}
+
+// signedRequest is req aimed at the storage URL. Like a followed
+// redirect, it drops credentials meant for Civitai when the host
+// changes; storage hosts reject a second authentication scheme.
+func signedRequest(req *http.Request, u *url.URL) *http.Request {
+	r := req.Clone(req.Context())
+	if u.Host != req.URL.Host {
+		r.Header.Del("Authorization")
+		r.Header.Del("Cookie")
+	}
+	r.URL = u
+	r.Host = ""
+	return r
+}
+
+var renewMu sync.Mutex
+
+// renewSigned resolves source again, unless another request already
+// replaced the stale signature.
+func renewSigned(req *http.Request, source string, stale *signedURL) (*signedURL, error) {
+	renewMu.Lock()
+	defer renewMu.Unlock()
+	signedMu.Lock()
+	cur := signedURLs[source]
+	signedMu.Unlock()
+	if cur != nil && cur != stale {
+		return cur, nil
+	}
+
+	headers := map[string]string{}
+	if v := req.Header.Get("Authorization"); v != "" {
+		headers["Authorization"] = v
+	}
+	if _, err := ProbeURL(req.Context(), source, headers); err != nil {
+		return nil, err
+	}
+	signedMu.Lock()
+	defer signedMu.Unlock()
+	if s := signedURLs[source]; s != nil && s != stale {
+		return s, nil
+	}
+	return nil, fmt.Errorf("%s no longer redirects to signed storage", source)
+}
@@ -0,0 +1,70 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSignedURLRenewedOnExpiry(t *testing.T) {
+	content := strings.Repeat("abcdefghij", 10)
+	var sig, redirects atomic.Int32
+	sig.Store(1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/download/models/7", func(w http.ResponseWriter, r *http.Request) {
+		redirects.Add(1)
+		http.Redirect(w, r, fmt.Sprintf("/cdn/f.bin?sig=%d", sig.Load()), http.StatusFound)
+	})
+	mux.HandleFunc("/cdn/f.bin", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("sig") != fmt.Sprint(sig.Load()) {
+			http.Error(w, "Request has expired", http.StatusForbidden)
+			return
+		}
+		http.ServeContent(w, r, "f.bin", time.Time{}, strings.NewReader(content))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	source := srv.URL + "/api/download/models/7"
+	if _, err := ProbeURL(context.Background(), source, nil); err != nil {
+		t.Fatal(err)
+	}
+	sig.Store(2) // the first signature lapses
+
+	req, _ := http.NewRequest("GET", source, nil)
+	req.Header.Set("Range", "bytes=10-19")
+	resp, err := GetHttpClient().GetRawClient().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent || string(b) != "abcdefghij" {
+		t.Fatalf("got %d %q", resp.StatusCode, b)
+	}
+	if n := redirects.Load(); n != 2 {
+		t.Errorf("download URL resolved %d times, want 2", n)
+	}
+}
+
+func TestSignatureExpiry(t *testing.T) {
+	u, _ := url.Parse("https://s3.example.com/f?X-Amz-Date=20240501T100000Z&X-Amz-Expires=3600&X-Amz-Signature=x")
+	if got, want := signatureExpiry(u), time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("amz: got %v, want %v", got, want)
+	}
+	u, _ = url.Parse("https://cdn.example.com/f?Expires=1714561200&Signature=x")
+	if got := signatureExpiry(u); got.Unix() != 1714561200 {
+		t.Errorf("expires: got %v", got)
+	}
+	u, _ = url.Parse("https://cdn.example.com/f")
+	if got := signatureExpiry(u); !got.IsZero() {
+		t.Errorf("unsigned: got %v", got)
+	}
+}
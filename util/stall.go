@@ -0,0 +1,78 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// ErrStalled is returned by reads of a response that received no data
+// for the stall timeout.
+var ErrStalled = errors.New("connection stalled")
+
+var stallTimeout atomic.Int64 // time.Duration; 0 disables
+
+// SetStallTimeout makes the shared client abort a request that goes d
+// without receiving a byte, waiting for headers included unless a
+// response header timeout is set. The failed read returns ErrStalled,
+// which the downloader retries like any other chunk error. 0 disables
+// the check. The wait for headers is only bounded on clients built
+// afterwards.
+func SetStallTimeout(d time.Duration) {
+	stallTimeout.Store(int64(d))
+}
+
+// stallTransport watches response bodies for stalls. It sits below the
+// rate limiter, so time spent throttled doesn't count as a stall.
+type stallTransport struct {
+	base http.RoundTripper
+}
+
+func (t *stallTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	d := time.Duration(stallTimeout.Load())
+	if d <= 0 {
+		return t.base.RoundTrip(req)
+	}
+	ctx, cancel := context.WithCancelCause(req.Context())
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel(nil)
+		return nil, err
+	}
+	resp.Body = &stallBody{ReadCloser: resp.Body, ctx: ctx, cancel: cancel, timeout: d}
+	return resp, nil
+}
+
+type stallBody struct {
+	io.ReadCloser
+	ctx     context.Context
+	cancel  context.CancelCauseFunc
+	timeout time.Duration
+	timer   *time.Timer
+}
+
+func (b *stallBody) Read(p []byte) (int, error) {
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.timeout, func() { b.cancel(ErrStalled) })
+	} else {
+		b.timer.Reset(b.timeout)
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.timer.Stop()
+	if err != nil && errors.Is(context.Cause(b.ctx), ErrStalled) {
+		err = fmt.Errorf("%w: no data for %s", ErrStalled, b.timeout)
+	}
+	return n, err
+}
+
+func (b *stallBody) Close() error {
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	b.cancel(nil)
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,46 @@
+package util
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStalledBodyAborts(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		w.Write([]byte("first bytes"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	stallTimeout.Store(int64(200 * time.Millisecond))
+	defer stallTimeout.Store(0)
+	client := &http.Client{Transport: &stallTransport{base: http.DefaultTransport}}
+
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	start := time.Now()
+	b, err := io.ReadAll(resp.Body)
+	if !errors.Is(err, ErrStalled) {
+		t.Fatalf("got %v, want ErrStalled", err)
+	}
+	if string(b) != "first bytes" {
+		t.Errorf("read %q before the stall", b)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("stall noticed after %s", d)
+	}
+}
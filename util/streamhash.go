@@ -0,0 +1,318 @@
+package util
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// hashStateSuffix names the saved hashing state next to a partial file.
+const hashStateSuffix = ".hash"
+
+// hashSaveEvery bounds the hashing progress a crash can lose.
+const hashSaveEvery = 256 << 20
+
+// StreamHash computes the Sums of a file while the downloader writes
+// it. Chunks are written out of order, so it keeps a cursor at the end
+// of the contiguous prefix known to be on disk and hashes forward from
+// there, reading back data that was just written and is still in the
+// page cache. What is left when the download ends, typically the last
+// chunk, is hashed by Finish.
+//
+// The state is saved next to the partial file, so a resumed download
+// continues hashing where the last run stopped. BLAKE3 has no
+// serializable state; a resumed download is checked without it.
+type StreamHash struct {
+	path string
+
+	mu     sync.Mutex
+	ranges [][2]int64 // written but not yet hashed, in arrival order
+
+	// Owned by the hashing goroutine until it stops.
+	hashes *hashSet
+	offset int64 // [0, offset) is hashed
+	saved  int64
+	file   *os.File
+	err    error
+
+	wake     chan struct{}
+	quit     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+	finished bool
+}
+
+// hashState is the saved form of a StreamHash.
+type hashState struct {
+	Offset int64  `json:"offset"`
+	SHA256 []byte `json:"sha256"`
+	CRC32  []byte `json:"crc32"`
+}
+
+// NewStreamHash starts hashing the partial file at path, picking up the
+// state saved by an earlier run when the file is still there. Call it
+// before the file is created; state left without a file is discarded.
+func NewStreamHash(path string) *StreamHash {
+	h := &StreamHash{
+		path:   path,
+		hashes: newHashSet(),
+		wake:   make(chan struct{}, 1),
+		quit:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	h.load()
+	go h.run()
+	return h
+}
+
+func (h *StreamHash) load() {
+	statePath := h.path + hashStateSuffix
+	fi, err := os.Stat(h.path)
+	if err != nil {
+		os.Remove(statePath)
+		return
+	}
+	data, err := os.ReadFile(statePath)
+	if err != nil {
+		return
+	}
+	var st hashState
+	if json.Unmarshal(data, &st) != nil || st.Offset <= 0 || st.Offset > fi.Size() {
+		return
+	}
+	hashes := newHashSet()
+	if hashes.sha.(encoding.BinaryUnmarshaler).UnmarshalBinary(st.SHA256) != nil ||
+		hashes.crc.(encoding.BinaryUnmarshaler).UnmarshalBinary(st.CRC32) != nil {
+		return
+	}
+	hashes.blake3 = nil
+	h.hashes, h.offset, h.saved = hashes, st.Offset, st.Offset
+}
+
+// Written reports that [start, end) of the file is on disk.
+func (h *StreamHash) Written(start, end int64) {
+	h.mu.Lock()
+	h.ranges = append(h.ranges, [2]int64{start, end})
+	h.mu.Unlock()
+	select {
+	case h.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (h *StreamHash) run() {
+	defer close(h.done)
+	for {
+		select {
+		case <-h.wake:
+			h.advance()
+		case <-h.quit:
+			// Catch up with what was reported before stopping.
+			h.advance()
+			return
+		}
+	}
+}
+
+// advance hashes as far as the written ranges reach without a gap.
+func (h *StreamHash) advance() {
+	for h.err == nil {
+		end := h.reach()
+		if end <= h.offset {
+			return
+		}
+		h.err = h.hashTo(end)
+		if h.err == nil && h.offset-h.saved >= hashSaveEvery {
+			h.save()
+		}
+	}
+}
+
+// reach takes the written ranges that touch the cursor and returns how
+// far they extend.
+func (h *StreamHash) reach() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	end := h.offset
+	for grown := true; grown; {
+		grown = false
+		kept := h.ranges[:0]
+		for _, r := range h.ranges {
+			switch {
+			case r[1] <= end:
+				// Already hashed, e.g. a chunk retried.
+			case r[0] <= end:
+				end, grown = r[1], true
+			default:
+				kept = append(kept, r)
+			}
+		}
+		h.ranges = kept
+	}
+	return end
+}
+
+// hashTo hashes the file from the cursor up to end, or to its end when
+// end is negative.
+func (h *StreamHash) hashTo(end int64) error {
+	if h.file == nil {
+		f, err := os.Open(h.path)
+		if err != nil {
+			return err
+		}
+		h.file = f
+	}
+	var r io.Reader = io.NewSectionReader(h.file, h.offset, 1<<62)
+	if end >= 0 {
+		r = io.LimitReader(r, end-h.offset)
+	}
+	n, err := io.Copy(h.hashes, r)
+	h.offset += n
+	if err == nil && end >= 0 && h.offset < end {
+		err = fmt.Errorf("%s: file ends at %d, expected %d bytes", h.path, h.offset, end)
+	}
+	return err
+}
+
+func (h *StreamHash) save() {
+	sha, err := h.hashes.sha.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return
+	}
+	crc, err := h.hashes.crc.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(hashState{Offset: h.offset, SHA256: sha, CRC32: crc})
+	if err != nil {
+		return
+	}
+	if os.WriteFile(h.path+hashStateSuffix, data, 0644) == nil {
+		h.saved = h.offset
+	}
+}
+
+func (h *StreamHash) stop() {
+	h.stopOnce.Do(func() {
+		close(h.quit)
+		<-h.done
+	})
+}
+
+// Finish hashes the rest of the complete file and returns its Sums. If
+// streaming failed along the way, the whole file is hashed again.
+func (h *StreamHash) Finish() (Sums, error) {
+	h.stop()
+	h.finished = true
+	defer os.Remove(h.path + hashStateSuffix)
+	if h.err == nil {
+		h.err = h.hashTo(-1)
+	}
+	if h.file != nil {
+		h.file.Close()
+	}
+	if h.err != nil {
+		return FileSums(h.path)
+	}
+	return h.hashes.sums(), nil
+}
+
+// Close stops hashing. Unless Finish was called, the state is saved
+// for the next run.
+func (h *StreamHash) Close() {
+	h.stop()
+	if h.finished {
+		return
+	}
+	if h.err == nil && h.offset > h.saved {
+		h.save()
+	}
+	if h.file != nil {
+		h.file.Close()
+	}
+}
+
+var (
+	watchMu  sync.Mutex
+	watchers = map[string]*StreamHash{}
+)
+
+// Watch feeds h the ranges the shared client delivers for url until the
+// returned function is called.
+func (h *StreamHash) Watch(url string) func() {
+	watchMu.Lock()
+	watchers[url] = h
+	watchMu.Unlock()
+	return func() {
+		watchMu.Lock()
+		if watchers[url] == h {
+			delete(watchers, url)
+		}
+		watchMu.Unlock()
+	}
+}
+
+// hashTransport tells the StreamHash watching a URL which ranges of it
+// were delivered. The downloader writes what it reads before reading
+// more, so once a body is closed all of it is on disk. Should that not
+// hold, the streamed sums are wrong and the final check against the
+// file on disk catches it.
+type hashTransport struct {
+	base http.RoundTripper
+}
+
+func (t *hashTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return t.base.RoundTrip(req)
+	}
+	watchMu.Lock()
+	h := watchers[req.URL.String()]
+	watchMu.Unlock()
+	if h == nil {
+		return t.base.RoundTrip(req)
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	var start int64
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusPartialContent:
+		if _, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-", &start); err != nil {
+			return resp, nil
+		}
+	default:
+		return resp, nil
+	}
+	resp.Body = &writtenBody{ReadCloser: resp.Body, h: h, start: start}
+	return resp, nil
+}
+
+type writtenBody struct {
+	io.ReadCloser
+	h     *StreamHash
+	start int64
+	n     int64
+	once  sync.Once
+}
+
+func (b *writtenBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+func (b *writtenBody) Close() error {
+	b.once.Do(func() {
+		// A byte or two is the downloader probing, not data it writes.
+		if b.n > 2 {
+			b.h.Written(b.start, b.start+b.n)
+		}
+	})
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,79 @@
+package util
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStreamHashOutOfOrder(t *testing.T) {
+	data := make([]byte, 1<<20+123)
+	rand.New(rand.NewSource(1)).Read(data)
+	path := filepath.Join(t.TempDir(), "f.part")
+	want, err := writeAndSum(path, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewStreamHash(path)
+	const chunk = 64 << 10
+	var ranges [][2]int64
+	for off := int64(0); off < int64(len(data)); off += chunk {
+		ranges = append(ranges, [2]int64{off, min(off+chunk, int64(len(data)))})
+	}
+	rand.New(rand.NewSource(2)).Shuffle(len(ranges), func(i, j int) { ranges[i], ranges[j] = ranges[j], ranges[i] })
+	for _, r := range ranges {
+		h.Written(r[0], r[1])
+	}
+	got, err := h.Finish()
+	h.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if FileExists(path + hashStateSuffix) {
+		t.Error("hash state left behind")
+	}
+}
+
+func TestStreamHashResume(t *testing.T) {
+	data := make([]byte, 300<<10)
+	rand.New(rand.NewSource(3)).Read(data)
+	path := filepath.Join(t.TempDir(), "f.part")
+	want, err := writeAndSum(path, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewStreamHash(path)
+	h.Written(0, 100<<10)
+	h.Close() // interrupted
+	if !FileExists(path + hashStateSuffix) {
+		t.Fatal("hash state not saved")
+	}
+
+	h = NewStreamHash(path)
+	if h.offset != 100<<10 {
+		t.Fatalf("resumed at %d", h.offset)
+	}
+	got, err := h.Finish()
+	h.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// BLAKE3 can't be resumed.
+	want.BLAKE3 = ""
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func writeAndSum(path string, data []byte) (Sums, error) {
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return Sums{}, err
+	}
+	return FileSums(path)
+}
@@ -0,0 +1,59 @@
+package util
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// TLSOptions configure how the shared client verifies servers and
+// authenticates itself.
+type TLSOptions struct {
+	// CAFile is a PEM bundle trusted in addition to the system roots,
+	// e.g. the certificate of a TLS-inspecting corporate gateway.
+	CAFile string
+	// CertFile and KeyFile are a PEM client certificate and its key,
+	// for gateways that require mutual TLS.
+	CertFile string
+	KeyFile  string
+	// Insecure disables certificate verification altogether.
+	Insecure bool
+}
+
+var tlsConfig *tls.Config
+
+// SetTLS applies o to the shared client, which serves both the API
+// requests and the chunk requests of the downloader. Like SetTransport,
+// it only affects clients built afterwards.
+func SetTLS(o TLSOptions) error {
+	cfg := &tls.Config{InsecureSkipVerify: o.Insecure}
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return fmt.Errorf("ca bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("ca bundle %s: no PEM certificates found", o.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if o.CertFile != "" || o.KeyFile != "" {
+		if o.CertFile == "" || o.KeyFile == "" {
+			return fmt.Errorf("client certificate needs both a certificate and a key file")
+		}
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return fmt.Errorf("client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	tlsConfig = cfg
+	return nil
+}
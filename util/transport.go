@@ -0,0 +1,89 @@
+package util
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// TransportOptions size and time the connections of the shared client.
+type TransportOptions struct {
+	// Concurrency is how many chunk requests a download sends to one
+	// host at once. As many connections are kept open for reuse, so
+	// chunks don't pay for a new TCP and TLS handshake each.
+	Concurrency int
+	DialTimeout time.Duration
+	// TLSHandshakeTimeout bounds the TLS handshake of a new connection.
+	TLSHandshakeTimeout time.Duration
+	// ResponseHeaderTimeout bounds the wait for the response headers;
+	// 0 uses the stall timeout.
+	ResponseHeaderTimeout time.Duration
+	// KeepAlive is the interval of TCP keep-alive probes, which detect
+	// dead idle connections; 0 disables them.
+	KeepAlive time.Duration
+	// HTTP2 uses HTTP/2 with servers that offer it. Requests to a host
+	// then share one connection, so chunk requests no longer get a
+	// connection each and more threads stop adding bandwidth.
+	HTTP2 bool
+	// SocketBuffer sets the kernel send and receive buffer of each
+	// connection. 0 leaves them to the OS, which tunes them itself on
+	// most systems; a fixed size can help on links with a large
+	// bandwidth-delay product where it doesn't.
+	SocketBuffer int
+}
+
+// DefaultTransportOptions are used until SetTransport is called.
+var DefaultTransportOptions = TransportOptions{
+	Concurrency:         8,
+	DialTimeout:         30 * time.Second,
+	TLSHandshakeTimeout: 10 * time.Second,
+	KeepAlive:           30 * time.Second,
+}
+
+var transportOptions = DefaultTransportOptions
+
+// SetTransport sets the connection options of the shared client. Like
+// the proxy and TLS settings, it only affects clients built afterwards,
+// so it must be called before the shared client is first used.
+func SetTransport(o TransportOptions) {
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+	transportOptions = o
+}
+
+func configureTransport(t *http.Transport, o TransportOptions) {
+	keepAlive := o.KeepAlive
+	if keepAlive == 0 {
+		keepAlive = -1 // net.Dialer's way of saying off
+	}
+	dialer := &net.Dialer{Timeout: o.DialTimeout, KeepAlive: keepAlive}
+	buf := o.SocketBuffer
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err == nil && buf > 0 {
+			if tc, ok := conn.(*net.TCPConn); ok {
+				tc.SetReadBuffer(buf)
+				tc.SetWriteBuffer(buf)
+			}
+		}
+		return conn, err
+	}
+	t.TLSHandshakeTimeout = o.TLSHandshakeTimeout
+	t.ResponseHeaderTimeout = o.ResponseHeaderTimeout
+	if o.ResponseHeaderTimeout == 0 {
+		t.ResponseHeaderTimeout = time.Duration(stallTimeout.Load())
+	}
+
+	// Go keeps only 2 idle connections per host by default, so with
+	// more chunk workers most connections were closed after one chunk.
+	t.MaxIdleConnsPerHost = max(o.Concurrency, 2)
+	t.MaxIdleConns = max(100, 2*t.MaxIdleConnsPerHost)
+
+	// A custom dialer or TLS config turns HTTP/2 off unless asked for.
+	t.ForceAttemptHTTP2 = o.HTTP2
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(o.HTTP2)
+	t.Protocols = protocols
+}
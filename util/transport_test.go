@@ -0,0 +1,102 @@
+package util
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetHttpClientConcurrent(t *testing.T) {
+	clients := make([]*HttpClient, 8)
+	var wg sync.WaitGroup
+	for i := range clients {
+		wg.Go(func() { clients[i] = GetHttpClient() })
+	}
+	wg.Wait()
+	for _, c := range clients {
+		if c != clients[0] {
+			t.Fatal("GetHttpClient returned different clients")
+		}
+	}
+}
+
+func TestSetTransportAppliesToNewClients(t *testing.T) {
+	t.Cleanup(func() { SetTransport(DefaultTransportOptions) })
+	before := NewHttpClient()
+	o := DefaultTransportOptions
+	o.Concurrency = 32
+	SetTransport(o)
+	if n := before.t.MaxIdleConnsPerHost; n != DefaultTransportOptions.Concurrency {
+		t.Errorf("built client changed: %d idle connections per host", n)
+	}
+	if n := NewHttpClient().t.MaxIdleConnsPerHost; n != 32 {
+		t.Errorf("new client: %d idle connections per host, want 32", n)
+	}
+}
+
+func TestTransportReusesConnections(t *testing.T) {
+	var conns atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte("chunk"))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, s http.ConnState) {
+		if s == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	tr := &http.Transport{}
+	o := DefaultTransportOptions
+	o.Concurrency = 8
+	configureTransport(tr, o)
+	client := &http.Client{Transport: tr}
+	for range 3 {
+		var wg sync.WaitGroup
+		for range 8 {
+			wg.Go(func() {
+				resp, err := client.Get(srv.URL)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				resp.Body.Close()
+			})
+		}
+		wg.Wait()
+	}
+	// Go's default of 2 idle connections per host needs over 20 here.
+	// A request may race an idle connection with a new dial, so allow
+	// a few spares.
+	if n := conns.Load(); n > 11 {
+		t.Errorf("%d connections for 8 workers", n)
+	}
+}
+
+func TestTransportHTTP2(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	for _, h2 := range []bool{true, false} {
+		tr := &http.Transport{TLSClientConfig: srv.Client().Transport.(*http.Transport).TLSClientConfig.Clone()}
+		o := DefaultTransportOptions
+		o.HTTP2 = h2
+		configureTransport(tr, o)
+		resp, err := (&http.Client{Transport: tr}).Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got := resp.ProtoMajor == 2; got != h2 {
+			t.Errorf("HTTP2 %v: got %s", h2, resp.Proto)
+		}
+	}
+}